@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mdomke/git-semver/v6/version"
+)
+
+// Backend selects how the history of the repository is read.
+type Backend int
+
+const (
+	GoGitBackend Backend = iota // reads the repository with go-git
+	GitBackend                  // runs the git binary found in PATH
+)
+
+func (b *Backend) String() string {
+	switch *b {
+	case GoGitBackend:
+		return "go-git"
+	case GitBackend:
+		return "git"
+	default:
+		panic(fmt.Errorf("unexpected backend %v", *b))
+	}
+}
+
+func (b *Backend) Set(value string) error {
+	switch value {
+	case "go-git":
+		*b = GoGitBackend
+	case "git":
+		*b = GitBackend
+	default:
+		return errors.New(`parse error`)
+	}
+	return nil
+}
+
+func (b *Backend) open() version.OpenBackend {
+	if *b == GitBackend {
+		return version.OpenGitCLI
+	}
+	return version.OpenGoGit
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendToString(t *testing.T) {
+	assert.PanicsWithError(t, "unexpected backend 8", func() {
+		backend := Backend(8)
+		_ = backend.String()
+	})
+
+	backend := GoGitBackend
+	assert.Equal(t, "go-git", backend.String())
+
+	backend = GitBackend
+	assert.Equal(t, "git", backend.String())
+}
+
+func TestParseBackend(t *testing.T) {
+	var backend Backend
+	require.EqualError(t, backend.Set("libgit2"), "parse error")
+
+	require.NoError(t, backend.Set("git"))
+	assert.Equal(t, GitBackend, backend)
+
+	require.NoError(t, backend.Set("go-git"))
+	assert.Equal(t, GoGitBackend, backend)
+}
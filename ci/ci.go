@@ -0,0 +1,113 @@
+// Package ci detects continuous integration systems from their well-known environment variables.
+//
+// CI systems usually check out the commit that is being built as a detached head, so that the
+// name of the branch can't be derived from the repository itself. The detectors in this package
+// recover the branch, pull-request number and build number from the environment instead.
+package ci
+
+import "strings"
+
+// Env holds the information that could be derived from the environment of a CI system.
+type Env struct {
+	Provider    string
+	Branch      string
+	PullRequest string
+	Build       string
+}
+
+// A Detector inspects the environment through getenv and reports whether it recognized the
+// CI system it is responsible for.
+type Detector func(getenv func(string) string) (Env, bool)
+
+// Detectors that are consulted by [Detect] in order.
+var Detectors = []Detector{
+	GitHubActions,
+	GitLabCI,
+	Jenkins,
+	Buildkite,
+}
+
+// Detect returns the environment of the first CI system that was recognized by one of the
+// [Detectors].
+func Detect(getenv func(string) string) (Env, bool) {
+	for _, detect := range Detectors {
+		if env, ok := detect(getenv); ok {
+			return env, true
+		}
+	}
+	return Env{}, false
+}
+
+// GitHubActions detects GitHub Actions workflows. The branch is derived from GITHUB_REF or
+// GITHUB_HEAD_REF for pull requests.
+func GitHubActions(getenv func(string) string) (Env, bool) {
+	if getenv("GITHUB_ACTIONS") != "true" {
+		return Env{}, false
+	}
+	env := Env{Provider: "github", Build: getenv("GITHUB_RUN_NUMBER")}
+	ref := getenv("GITHUB_REF")
+	switch {
+	case strings.HasPrefix(ref, "refs/heads/"):
+		env.Branch = strings.TrimPrefix(ref, "refs/heads/")
+	case strings.HasPrefix(ref, "refs/pull/"):
+		env.Branch = getenv("GITHUB_HEAD_REF")
+		env.PullRequest = strings.TrimSuffix(strings.TrimPrefix(ref, "refs/pull/"), "/merge")
+	}
+	return env, true
+}
+
+// GitLabCI detects GitLab CI/CD pipelines. For merge request pipelines the source branch and the
+// merge request IID are used.
+func GitLabCI(getenv func(string) string) (Env, bool) {
+	if getenv("GITLAB_CI") != "true" {
+		return Env{}, false
+	}
+	env := Env{
+		Provider:    "gitlab",
+		Branch:      getenv("CI_COMMIT_BRANCH"),
+		PullRequest: getenv("CI_MERGE_REQUEST_IID"),
+		Build:       getenv("CI_PIPELINE_IID"),
+	}
+	if env.PullRequest != "" {
+		env.Branch = getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_NAME")
+	}
+	return env, true
+}
+
+// Jenkins detects Jenkins builds. Multibranch pipelines provide BRANCH_NAME and CHANGE_ID for
+// pull requests, whereas the git plugin provides GIT_BRANCH including the remote name.
+func Jenkins(getenv func(string) string) (Env, bool) {
+	if getenv("JENKINS_URL") == "" {
+		return Env{}, false
+	}
+	env := Env{
+		Provider:    "jenkins",
+		Branch:      getenv("BRANCH_NAME"),
+		PullRequest: getenv("CHANGE_ID"),
+		Build:       getenv("BUILD_NUMBER"),
+	}
+	switch {
+	case env.PullRequest != "":
+		env.Branch = getenv("CHANGE_BRANCH")
+	case env.Branch == "":
+		env.Branch = strings.TrimPrefix(getenv("GIT_BRANCH"), "origin/")
+	}
+	return env, true
+}
+
+// Buildkite detects Buildkite builds. BUILDKITE_PULL_REQUEST is set to false for builds that
+// are not triggered by a pull request.
+func Buildkite(getenv func(string) string) (Env, bool) {
+	if getenv("BUILDKITE") != "true" {
+		return Env{}, false
+	}
+	env := Env{
+		Provider: "buildkite",
+		Branch:   getenv("BUILDKITE_BRANCH"),
+		Build:    getenv("BUILDKITE_BUILD_NUMBER"),
+	}
+	if pr := getenv("BUILDKITE_PULL_REQUEST"); pr != "false" {
+		env.PullRequest = pr
+	}
+	return env, true
+}
@@ -0,0 +1,178 @@
+package ci
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeEnv(env map[string]string) func(string) string {
+	return func(key string) string {
+		return env[key]
+	}
+}
+
+type detectorTest struct {
+	desc string
+	env  map[string]string
+	ci   Env
+	ok   bool
+}
+
+func testDetector(t *testing.T, detect Detector, tests []detectorTest) {
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			env, ok := detect(fakeEnv(test.env))
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.ci, env)
+		})
+	}
+}
+
+func TestGitHubActions(t *testing.T) {
+	testDetector(t, GitHubActions, []detectorTest{
+		{
+			desc: "Not GitHub Actions",
+			env:  map[string]string{"GITHUB_REF": "refs/heads/main"},
+		},
+		{
+			desc: "Branch",
+			env: map[string]string{
+				"GITHUB_ACTIONS":    "true",
+				"GITHUB_REF":        "refs/heads/feature/foo",
+				"GITHUB_RUN_NUMBER": "17",
+			},
+			ci: Env{Provider: "github", Branch: "feature/foo", Build: "17"},
+			ok: true,
+		},
+		{
+			desc: "Pull request",
+			env: map[string]string{
+				"GITHUB_ACTIONS":    "true",
+				"GITHUB_REF":        "refs/pull/42/merge",
+				"GITHUB_HEAD_REF":   "fix-bug",
+				"GITHUB_RUN_NUMBER": "18",
+			},
+			ci: Env{Provider: "github", Branch: "fix-bug", PullRequest: "42", Build: "18"},
+			ok: true,
+		},
+		{
+			desc: "Tag",
+			env:  map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_REF": "refs/tags/v1.2.3"},
+			ci:   Env{Provider: "github"},
+			ok:   true,
+		},
+	})
+}
+
+func TestGitLabCI(t *testing.T) {
+	testDetector(t, GitLabCI, []detectorTest{
+		{
+			desc: "Not GitLab CI",
+			env:  map[string]string{"CI_COMMIT_BRANCH": "main"},
+		},
+		{
+			desc: "Branch",
+			env: map[string]string{
+				"GITLAB_CI":        "true",
+				"CI_COMMIT_BRANCH": "main",
+				"CI_PIPELINE_IID":  "123",
+			},
+			ci: Env{Provider: "gitlab", Branch: "main", Build: "123"},
+			ok: true,
+		},
+		{
+			desc: "Merge request",
+			env: map[string]string{
+				"GITLAB_CI":                           "true",
+				"CI_MERGE_REQUEST_IID":                "7",
+				"CI_MERGE_REQUEST_SOURCE_BRANCH_NAME": "feature/bar",
+				"CI_PIPELINE_IID":                     "124",
+			},
+			ci: Env{Provider: "gitlab", Branch: "feature/bar", PullRequest: "7", Build: "124"},
+			ok: true,
+		},
+	})
+}
+
+func TestJenkins(t *testing.T) {
+	testDetector(t, Jenkins, []detectorTest{
+		{
+			desc: "Not Jenkins",
+			env:  map[string]string{"BRANCH_NAME": "main"},
+		},
+		{
+			desc: "Multibranch pipeline",
+			env: map[string]string{
+				"JENKINS_URL":  "https://ci.example.org/",
+				"BRANCH_NAME":  "main",
+				"BUILD_NUMBER": "99",
+			},
+			ci: Env{Provider: "jenkins", Branch: "main", Build: "99"},
+			ok: true,
+		},
+		{
+			desc: "Multibranch pull request",
+			env: map[string]string{
+				"JENKINS_URL":   "https://ci.example.org/",
+				"BRANCH_NAME":   "PR-5",
+				"CHANGE_ID":     "5",
+				"CHANGE_BRANCH": "feature/baz",
+				"BUILD_NUMBER":  "100",
+			},
+			ci: Env{Provider: "jenkins", Branch: "feature/baz", PullRequest: "5", Build: "100"},
+			ok: true,
+		},
+		{
+			desc: "Git plugin",
+			env: map[string]string{
+				"JENKINS_URL":  "https://ci.example.org/",
+				"GIT_BRANCH":   "origin/release/1.4",
+				"BUILD_NUMBER": "101",
+			},
+			ci: Env{Provider: "jenkins", Branch: "release/1.4", Build: "101"},
+			ok: true,
+		},
+	})
+}
+
+func TestBuildkite(t *testing.T) {
+	testDetector(t, Buildkite, []detectorTest{
+		{
+			desc: "Not Buildkite",
+			env:  map[string]string{"BUILDKITE_BRANCH": "main"},
+		},
+		{
+			desc: "Branch",
+			env: map[string]string{
+				"BUILDKITE":              "true",
+				"BUILDKITE_BRANCH":       "main",
+				"BUILDKITE_PULL_REQUEST": "false",
+				"BUILDKITE_BUILD_NUMBER": "3",
+			},
+			ci: Env{Provider: "buildkite", Branch: "main", Build: "3"},
+			ok: true,
+		},
+		{
+			desc: "Pull request",
+			env: map[string]string{
+				"BUILDKITE":              "true",
+				"BUILDKITE_BRANCH":       "feature/qux",
+				"BUILDKITE_PULL_REQUEST": "12",
+				"BUILDKITE_BUILD_NUMBER": "4",
+			},
+			ci: Env{Provider: "buildkite", Branch: "feature/qux", PullRequest: "12", Build: "4"},
+			ok: true,
+		},
+	})
+}
+
+func TestDetect(t *testing.T) {
+	env, ok := Detect(fakeEnv(map[string]string{}))
+	assert.False(t, ok)
+	assert.Equal(t, Env{}, env)
+
+	env, ok = Detect(fakeEnv(map[string]string{"BUILDKITE": "true", "BUILDKITE_BRANCH": "main"}))
+	assert.True(t, ok)
+	assert.Equal(t, Env{Provider: "buildkite", Branch: "main"}, env)
+}
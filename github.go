@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// writeGitHub appends the computed fields as step outputs to the file named by GITHUB_OUTPUT
+// and a short markdown summary to the file named by GITHUB_STEP_SUMMARY.
+func writeGitHub(getenv func(string) string, info Info) error {
+	if path := getenv("GITHUB_OUTPUT"); path != "" {
+		if err := appendFile(path, func(w io.Writer) error {
+			return writeGitHubOutput(w, info)
+		}); err != nil {
+			return fmt.Errorf("failed to write step outputs: %w", err)
+		}
+	}
+	if path := getenv("GITHUB_STEP_SUMMARY"); path != "" {
+		if err := appendFile(path, func(w io.Writer) error {
+			return writeGitHubSummary(w, info)
+		}); err != nil {
+			return fmt.Errorf("failed to write step summary: %w", err)
+		}
+	}
+	return nil
+}
+
+func appendFile(path string, write func(io.Writer) error) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644) // nolint: gosec
+	if err != nil {
+		return err
+	}
+	if err = write(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func writeGitHubOutput(out io.Writer, info Info) error {
+	for _, variable := range info.env() {
+		if _, err := fmt.Fprintf(out, "%s=%s\n", strings.ToLower(variable.name), variable.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeGitHubSummary(out io.Writer, info Info) error {
+	lastTag := info.LastTag
+	if lastTag == "" {
+		lastTag = "none"
+	}
+	branch := info.Branch
+	if branch == "" {
+		branch = "detached"
+	}
+	var summary strings.Builder
+	summary.WriteString("### git-semver\n\n| Field | Value |\n| --- | --- |\n")
+	for _, row := range [][2]string{
+		{"Version", info.Version},
+		{"Last tag", lastTag},
+		{"Commits since tag", strconv.Itoa(info.CommitsSinceTag)},
+		{"Branch", branch},
+		{"Commit", info.Hash},
+	} {
+		fmt.Fprintf(&summary, "| %s | `%s` |\n", row[0], row[1])
+	}
+	summary.WriteString("\n")
+	_, err := io.WriteString(out, summary.String())
+	return err
+}
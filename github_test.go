@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fakeEnv(env map[string]string) func(string) string {
+	return func(key string) string {
+		return env[key]
+	}
+}
+
+func TestWriteGitHub(t *testing.T) {
+	dir := t.TempDir()
+	outputPath := filepath.Join(dir, "output")
+	summaryPath := filepath.Join(dir, "summary")
+	require.NoError(t, os.WriteFile(outputPath, []byte("previous=step\n"), 0600))
+	env := fakeEnv(map[string]string{
+		"GITHUB_OUTPUT":       outputPath,
+		"GITHUB_STEP_SUMMARY": summaryPath,
+	})
+	info := Info{
+		Version:         "1.2.4-dev.4",
+		Major:           1,
+		LastTag:         "1.2.3",
+		CommitsSinceTag: 4,
+		Hash:            "fcf2c8fa",
+		Formats:         map[string]string{"noPatch": "1.2"},
+	}
+	require.NoError(t, writeGitHub(env, info))
+
+	output, err := os.ReadFile(outputPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "previous=step\nversion=1.2.4-dev.4\n")
+	assert.Contains(t, string(output), "\nmajor=1\n")
+	assert.Contains(t, string(output), "\nno_patch=1.2\n")
+
+	summary, err := os.ReadFile(summaryPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(summary), "| Version | `1.2.4-dev.4` |\n")
+	assert.Contains(t, string(summary), "| Branch | `detached` |\n")
+}
+
+func TestHandleGitHub(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "output")
+	var buf bytes.Buffer
+	cfg := &Config{
+		github: true,
+		stdout: &buf,
+		stderr: &buf,
+		getenv: fakeEnv(map[string]string{
+			"GITHUB_ACTIONS": "true",
+			"GITHUB_OUTPUT":  outputPath,
+		}),
+	}
+	assert.Equal(t, 0, handle(cfg, ""))
+	output, err := os.ReadFile(outputPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "version="+buf.String())
+
+	buf.Reset()
+	cfg.getenv = fakeEnv(map[string]string{})
+	assert.Equal(t, 0, handle(cfg, ""))
+	assert.Contains(t, buf.String(), "Ignoring -github outside of GitHub Actions\n")
+}
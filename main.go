@@ -2,12 +2,16 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log"
 	"os"
+	"strconv"
 	"strings"
 
+	"github.com/mdomke/git-semver/v6/ci"
 	"github.com/mdomke/git-semver/v6/version"
 )
 
@@ -18,15 +22,94 @@ type Config struct {
 	excludeHash       bool
 	excludeMeta       bool
 	setMeta           string
+	setPreRelease     string
 	excludePreRelease bool
 	excludePatch      bool
 	excludeMinor      bool
 	guardRelease      bool
-	matchPattern      string
+	exactMatch        bool
+	requireAnnotated  bool
+	requireSigned     bool
+	matchPatterns     patterns
+	excludePatterns   patterns
+	matchRegexps      patterns
+	excludeRegexps    patterns
+	parseRegex        string
+	lenient           bool
+	initialVersion    string
+	selection         version.Selection
+	shallowFallback   bool
+	maxDepth          positive
+	backend           Backend
+	cache             bool
 	releaseTarget     version.Target
+	channels          version.ChannelRules
+	releaseLine       string
+	monotonic         Monotonic
+	output            Output
+	envPrefix         string
+	github            bool
+	verbose           bool
+	workspace         bool
+	jobs              limit
+	foreign           bool
 	args              []string
 	stderr            io.Writer
 	stdout            io.Writer
+	getenv            func(string) string
+}
+
+// patterns collects the values of a flag that can be repeated.
+type patterns []string
+
+func (p *patterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patterns) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
+// limit is an optional non-negative number given on the command line.
+type limit struct {
+	value int
+	set   bool
+}
+
+func (l *limit) String() string {
+	if !l.set {
+		return ""
+	}
+	return strconv.Itoa(l.value)
+}
+
+func (l *limit) Set(value string) error {
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return errors.New(`parse error`)
+	}
+	*l = limit{value: count, set: true}
+	return nil
+}
+
+// positive is a limit that must be greater than zero.
+type positive struct {
+	limit
+}
+
+func (p *positive) Set(value string) error {
+	if err := p.limit.Set(value); err != nil || p.value == 0 {
+		return errors.New(`parse error`)
+	}
+	return nil
+}
+
+func (cfg *Config) lookupEnv(key string) string {
+	if cfg.getenv == nil {
+		return os.Getenv(key)
+	}
+	return cfg.getenv(key)
 }
 
 func parseFlags(progname string, args []string) (*Config, string, error) {
@@ -40,11 +123,61 @@ func parseFlags(progname string, args []string) (*Config, string, error) {
 	flags := flag.NewFlagSet(progname, flag.ContinueOnError)
 	flags.SetOutput(&buf)
 	flags.StringVar(&cfg.prefix, "prefix", "", "prefix of version string e.g. v (default: none)")
-	flags.StringVar(&cfg.matchPattern, "match", "", "only consider tags matching glob pattern (e.g. v1.2.*)")
+	flags.Var(&cfg.matchPatterns, "match", "only consider tags matching glob pattern (e.g. v1.2.*), can be repeated")
+	flags.Var(&cfg.excludePatterns, "exclude", "skip tags matching glob pattern (e.g. *-nightly*), can be repeated")
+	flags.Var(&cfg.matchRegexps, "match-regex", "only consider tags matching regular expression, can be repeated")
+	flags.Var(&cfg.excludeRegexps, "exclude-regex", "skip tags matching regular expression, can be repeated")
+	flags.StringVar(
+		&cfg.parseRegex,
+		"parse-regex",
+		"",
+		"parse tags with regular expression with named groups major, minor, patch, pre and meta (default: none)",
+	)
+	flags.BoolVar(
+		&cfg.lenient,
+		"lenient",
+		false,
+		"accept tags with one or two components like v2.1 and set the missing ones to zero (default: false)",
+	)
+	flags.StringVar(
+		&cfg.initialVersion,
+		"initial-version",
+		"",
+		"base version if no tag matches (e.g. 0.1.0) (default: 0.0.0)",
+	)
+	flags.Var(
+		&cfg.selection,
+		"select",
+		"select among tags of the same commit (newest, highest-semver, prefer-release or prefer-annotated) (default: newest)",
+	)
+	flags.BoolVar(
+		&cfg.shallowFallback,
+		"shallow-fallback",
+		false,
+		"use the highest tag not newer than the shallow boundary if a shallow clone lacks the last tag (default: false)",
+	)
+	flags.Var(&cfg.maxDepth, "max-depth", "fail if no tag is found within N commits (default: unlimited)")
+	flags.Var(
+		&cfg.backend,
+		"backend",
+		"read the repository with go-git or the git binary (go-git or git) (default: go-git)",
+	)
+	flags.BoolVar(
+		&cfg.cache,
+		"cache",
+		false,
+		"cache the result in the git directory until HEAD or the tags change (default: false)",
+	)
 	flags.StringVar(&cfg.format, "format", "", "format string (e.g.: x.y.z-p+m)")
 	flags.BoolVar(&cfg.excludeHash, "no-hash", false, "exclude commit hash (default: false)")
 	flags.BoolVar(&cfg.excludeMeta, "no-meta", false, "exclude build metadata (default: false)")
-	flags.StringVar(&cfg.setMeta, "set-meta", "", "set build metadata (default: none)")
+	flags.StringVar(&cfg.setMeta, "set-meta", "", "set build metadata, may be a template (default: none)")
+	flags.StringVar(
+		&cfg.setPreRelease,
+		"set-pre",
+		"",
+		"set pre-release identifier, may be a template (e.g. pr.{{.PullRequest}}) (default: none)",
+	)
 	flags.BoolVar(&cfg.excludePreRelease, "no-pre", false, "exclude pre-release version (default: false)")
 	flags.BoolVar(&cfg.excludePatch, "no-patch", false, "exclude patch version (default: false)")
 	flags.BoolVar(&cfg.excludeMinor, "no-minor", false, "exclude pre-release version (default: false)")
@@ -55,13 +188,73 @@ func parseFlags(progname string, args []string) (*Config, string, error) {
 		false,
 		"ignore shorthand options if version contains pre-release (default: false)",
 	)
+	flags.BoolVar(
+		&cfg.exactMatch,
+		"exact-match",
+		false,
+		"fail with exit code 3 unless the head commit is tagged (default: false)",
+	)
+	flags.BoolVar(
+		&cfg.requireAnnotated,
+		"require-annotated",
+		false,
+		"like -exact-match, but the tag must be annotated (default: false)",
+	)
+	flags.BoolVar(
+		&cfg.requireSigned,
+		"require-signed",
+		false,
+		"like -exact-match, but the tag must be signed (default: false)",
+	)
 	flags.Var(
 		&cfg.releaseTarget,
 		"target",
 		"set release target (major, minor, patch or dev) to bump version to (default: dev)",
 	)
+	flags.Var(
+		&cfg.channels,
+		"channel",
+		"select pre-release label for branches with pattern=label (e.g. release/*=rc), can be repeated",
+	)
+	flags.StringVar(
+		&cfg.releaseLine,
+		"release-line",
+		"",
+		"restrict to maintenance release line X.Y, or auto to derive it from the branch (default: none)",
+	)
+	flags.Var(
+		&cfg.monotonic,
+		"monotonic",
+		"fail or bump if the version is not greater than the highest tag (off, fail or bump) (default: off)",
+	)
+	flags.Var(&cfg.output, "output", "set output format (text, json, env or export) (default: text)")
+	flags.StringVar(
+		&cfg.envPrefix,
+		"env-prefix",
+		DefaultEnvPrefix,
+		"prefix of variable names for env and export output",
+	)
+	flags.BoolVar(
+		&cfg.github,
+		"github",
+		false,
+		"write fields to GITHUB_OUTPUT and GITHUB_STEP_SUMMARY in GitHub Actions (default: false)",
+	)
+	flags.BoolVar(&cfg.verbose, "verbose", false, "print diagnostics like skipped tags to stderr (default: false)")
+	flags.BoolVar(
+		&cfg.workspace,
+		"workspace",
+		false,
+		"print the versions of all repositories in the given paths as table or JSON (default: false)",
+	)
+	flags.Var(&cfg.jobs, "jobs", "number of repositories versioned in parallel with -workspace (default: number of CPUs)")
 	flags.Usage = func() {
-		fmt.Fprintf(flags.Output(), "Usage: %s [opts] [<repo>]\n\nOptions:\n", progname)
+		fmt.Fprintf(
+			flags.Output(),
+			"Usage: %s [opts] [<repo>]\n       %s -workspace [opts] [<path>...]\n\nOptions:\n",
+			progname,
+			progname,
+		)
 		flags.PrintDefaults()
 	}
 
@@ -69,6 +262,10 @@ func parseFlags(progname string, args []string) (*Config, string, error) {
 	if err != nil {
 		return nil, buf.String(), err
 	}
+	if cfg.workspace && (cfg.github || cfg.output == EnvOutput || cfg.output == ExportOutput) {
+		err = errors.New("-workspace only supports text and json output")
+		return nil, err.Error(), err
+	}
 
 	cfg.args = flags.Args()
 	cfg.stderr = os.Stderr
@@ -76,6 +273,45 @@ func parseFlags(progname string, args []string) (*Config, string, error) {
 	return &cfg, buf.String(), nil
 }
 
+// Exit codes of git-semver. Scripts can use them to react to the different failure classes.
+const (
+	exitOK            = 0 // the version was printed
+	exitError         = 1 // any failure that isn't covered by a more specific code
+	exitUsage         = 2 // invalid command line options, match patterns or tag parser
+	exitNoMatchingTag = 3 // a tag was required but none matched (e.g. with -exact-match)
+	exitNotRepository = 4 // the path isn't inside a git repository
+	exitNoHead        = 5 // the head of the repository can't be resolved (e.g. no commits)
+	exitInvalidTag    = 6 // the last tag can't be parsed as version
+	exitInvalidFormat = 7 // the format string is invalid
+	exitShallowClone  = 8 // the history of a shallow clone ends before the last tag
+)
+
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, version.ErrNoMatchingTag):
+		return exitNoMatchingTag
+	case errors.Is(err, version.ErrNotRepository):
+		return exitNotRepository
+	case errors.Is(err, version.ErrNoHead):
+		return exitNoHead
+	case errors.Is(err, version.ErrInvalidTag):
+		return exitInvalidTag
+	case errors.Is(err, version.ErrInvalidFormat):
+		return exitInvalidFormat
+	case errors.Is(err, version.ErrShallowClone):
+		return exitShallowClone
+	case errors.Is(err, version.ErrInvalidPattern),
+		errors.Is(err, version.ErrInvalidParser),
+		errors.Is(err, version.ErrInvalidOption),
+		errors.Is(err, version.ErrInvalidReleaseLine):
+		return exitUsage
+	default:
+		return exitError
+	}
+}
+
 func selectFormat(cfg *Config, v version.Version) string {
 	var format string
 	switch {
@@ -104,23 +340,180 @@ func selectFormat(cfg *Config, v version.Version) string {
 	return format
 }
 
-func handle(cfg *Config, repoPath string) int {
+// releaseLineOptions returns the options that limit the tags to the release line given with
+// -release-line. With auto the line is derived from the checked-out branch or the branch of the
+// CI environment while describing the repository.
+func releaseLineOptions(cfg *Config, env ci.Env) ([]version.Option, error) {
+	if cfg.releaseLine == "auto" {
+		return []version.Option{version.WithBranchReleaseLine(env.Branch), version.WithRequireTag()}, nil
+	}
+	line, err := version.ParseReleaseLine(cfg.releaseLine)
+	if err != nil {
+		return nil, err
+	}
+	if err = line.CheckTarget(cfg.releaseTarget); err != nil {
+		return nil, err
+	}
+	return []version.Option{version.WithReleaseLine(line), version.WithRequireTag()}, nil
+}
+
+func (cfg *Config) matchOptions() []version.Option {
+	var opts []version.Option
+	for _, pattern := range cfg.matchPatterns {
+		opts = append(opts, version.WithMatchPattern(pattern))
+	}
+	for _, expr := range cfg.matchRegexps {
+		opts = append(opts, version.WithMatchRegexp(expr))
+	}
+	for _, pattern := range cfg.excludePatterns {
+		opts = append(opts, version.WithExcludePattern(pattern))
+	}
+	for _, expr := range cfg.excludeRegexps {
+		opts = append(opts, version.WithExcludeRegexp(expr))
+	}
+	return opts
+}
+
+// run calculates the version of the repository at repoPath and prints it to cfg.stdout.
+func run(cfg *Config, repoPath string) error {
 	if repoPath == "" {
 		var err error
 		repoPath, err = os.Getwd()
 		if err != nil {
-			fmt.Fprintln(cfg.stderr, err)
-			return 1
+			return err
 		}
 	}
-	ver, err := version.NewFromRepo(repoPath, cfg.prefix, cfg.matchPattern)
+	info, err := compute(cfg, repoPath)
 	if err != nil {
-		fmt.Fprintln(cfg.stderr, err)
-		return 1
+		return err
+	}
+	if cfg.github {
+		if info.CI != "github" {
+			fmt.Fprintln(cfg.stderr, "Ignoring -github outside of GitHub Actions")
+		} else if err = writeGitHub(cfg.lookupEnv, info); err != nil {
+			return err
+		}
+	}
+	return writeInfo(cfg, info)
+}
+
+// compute calculates all fields of the repository at repoPath. Warnings are printed to
+// cfg.stderr.
+func compute(cfg *Config, repoPath string) (Info, error) {
+	env, _ := ci.Detect(cfg.lookupEnv)
+	if cfg.foreign {
+		// The branch and pull request of the CI job belong to the repository that runs it.
+		env.Branch, env.PullRequest = "", ""
+	}
+	opts := append(
+		cfg.matchOptions(),
+		version.WithPrefix(cfg.prefix),
+		version.WithSelection(cfg.selection),
+		version.WithBackend(cfg.backend.open()),
+	)
+	parser := version.PrefixParser(cfg.prefix)
+	if cfg.initialVersion != "" {
+		opts = append(opts, version.WithInitialVersion(cfg.initialVersion))
+	}
+	if cfg.shallowFallback {
+		opts = append(opts, version.WithShallowFallback())
+	}
+	if cfg.maxDepth.set {
+		opts = append(opts, version.WithMaxDepth(cfg.maxDepth.value))
+	}
+	if cfg.exactMatch || cfg.requireAnnotated || cfg.requireSigned {
+		opts = append(opts, version.WithExactMatch())
+	}
+	if cfg.cache {
+		opts = append(opts, version.WithCache())
+	}
+	if cfg.lenient {
+		parser = version.LenientParser(cfg.prefix)
+		opts = append(opts, version.WithParser(parser))
+	}
+	if cfg.parseRegex != "" {
+		regexpParser, err := version.NewRegexpParser(cfg.parseRegex)
+		if err != nil {
+			return Info{}, err
+		}
+		parser = regexpParser
+		opts = append(opts, version.WithParser(regexpParser))
+	}
+	if cfg.verbose {
+		opts = append(opts, version.WithLogger(log.New(cfg.stderr, "", 0)))
+	}
+	if cfg.releaseLine != "" {
+		lineOpts, err := releaseLineOptions(cfg, env)
+		if err != nil {
+			return Info{}, err
+		}
+		opts = append(opts, lineOpts...)
+	}
+	if cfg.output != TextOutput || cfg.github {
+		opts = append(opts, version.WithDirtyCheck())
+	}
+	head, err := version.GitDescribe(repoPath, opts...)
+	if err != nil {
+		return Info{}, err
+	}
+	if head.Shallow {
+		fmt.Fprintln(
+			cfg.stderr,
+			"Warning: shallow clone, the version may be inaccurate. Fetch more history with git fetch --unshallow",
+		)
+	}
+	if cfg.exactMatch || cfg.requireAnnotated || cfg.requireSigned {
+		if err = head.ExactMatch(cfg.requireAnnotated, cfg.requireSigned); err != nil {
+			return Info{}, err
+		}
+	}
+	if head.Branch == "" {
+		head.Branch = env.Branch
+	}
+	if cfg.releaseLine == "auto" {
+		line, err := version.ReleaseLineFromBranch(head.Branch)
+		if err != nil {
+			return Info{}, err
+		}
+		if err = line.CheckTarget(cfg.releaseTarget); err != nil {
+			return Info{}, err
+		}
+	}
+	ver, err := version.NewFromHeadWithParser(head, parser)
+	if err != nil {
+		return Info{}, err
 	}
 	ver = ver.BumpTo(cfg.releaseTarget)
+	data := templateData{Env: env, Commits: head.CommitsSinceTag, Hash: head.Hash}
+	data.Branch = head.Branch
+	if label, found := cfg.channels.Match(head.Branch); found {
+		label, err = expandTemplate("channel", label, data)
+		if err != nil {
+			return Info{}, err
+		}
+		ver.Channel = version.SanitizeIdentifier(label)
+	}
+	if cfg.setPreRelease != "" {
+		pre, err := expandTemplate("pre-release", cfg.setPreRelease, data)
+		if err != nil {
+			return Info{}, err
+		}
+		if pre = version.SanitizeIdentifier(pre); pre != "" {
+			ver = ver.WithPreRelease(pre)
+		}
+	}
 	if cfg.setMeta != "" {
-		ver.Meta = cfg.setMeta
+		meta, err := expandTemplate("meta", cfg.setMeta, data)
+		if err != nil {
+			return Info{}, err
+		}
+		if meta != "" {
+			ver.Meta = meta
+		}
+	}
+	ver, err = ensureMonotonic(cfg.monotonic, ver, head, parser)
+	if err != nil {
+		return Info{}, err
 	}
 	if cfg.prefix != "" {
 		ver.Prefix = cfg.prefix
@@ -130,11 +523,17 @@ func handle(cfg *Config, repoPath string) int {
 	}
 	s, err := ver.Format(selectFormat(cfg, ver))
 	if err != nil {
+		return Info{}, err
+	}
+	return newInfo(s, ver, head, env), nil
+}
+
+func handle(cfg *Config, repoPath string) int {
+	if err := run(cfg, repoPath); err != nil {
 		fmt.Fprintln(cfg.stderr, err)
-		return 1
+		return exitCode(err)
 	}
-	fmt.Fprintln(cfg.stdout, s)
-	return 0
+	return exitOK
 }
 
 func main() {
@@ -142,11 +541,14 @@ func main() {
 	if err != nil {
 		fmt.Println(out)
 		if err == flag.ErrHelp {
-			os.Exit(0)
+			os.Exit(exitOK)
 		}
-		os.Exit(2)
+		os.Exit(exitUsage)
 	}
 
+	if cfg.workspace {
+		os.Exit(handleWorkspace(cfg))
+	}
 	var path string
 	if len(cfg.args) > 0 {
 		path = cfg.args[0]
@@ -2,11 +2,18 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/mdomke/git-semver/v6/version"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -27,6 +34,10 @@ func TestParseFlags(t *testing.T) {
 				args:         []string{"repo-root"},
 			},
 		},
+		{
+			args: []string{"-exact-match", "-require-annotated", "-require-signed"},
+			cfg:  &Config{exactMatch: true, requireAnnotated: true, requireSigned: true, args: []string{}},
+		},
 		{
 			args: []string{"-no-hash"},
 			cfg:  &Config{excludeHash: true, args: []string{}},
@@ -55,10 +66,85 @@ func TestParseFlags(t *testing.T) {
 			args: []string{"-set-meta", "finleap"},
 			cfg:  &Config{setMeta: "finleap", args: []string{}},
 		},
+		{
+			args: []string{"-set-pre", "pr.{{.PullRequest}}"},
+			cfg:  &Config{setPreRelease: "pr.{{.PullRequest}}", args: []string{}},
+		},
+		{
+			args: []string{"-channel", "main=dev", "-channel", "feature/*={{.Branch}}"},
+			cfg: &Config{
+				channels: version.ChannelRules{
+					{Pattern: "main", Label: "dev"},
+					{Pattern: "feature/*", Label: "{{.Branch}}"},
+				},
+				args: []string{},
+			},
+		},
+		{
+			args:     []string{"-channel", "main"},
+			hasError: true,
+		},
+		{
+			args: []string{"-release-line", "auto"},
+			cfg:  &Config{releaseLine: "auto", args: []string{}},
+		},
+		{
+			args: []string{"-monotonic", "bump"},
+			cfg:  &Config{monotonic: MonotonicBump, args: []string{}},
+		},
 		{
 			args: []string{"-target", "minor"},
 			cfg:  &Config{releaseTarget: version.Minor, args: []string{}},
 		},
+		{
+			args: []string{"-output", "json"},
+			cfg:  &Config{output: JSONOutput, args: []string{}},
+		},
+		{
+			args: []string{"-output", "env", "-env-prefix", "APP_"},
+			cfg:  &Config{output: EnvOutput, envPrefix: "APP_", args: []string{}},
+		},
+		{
+			args: []string{"-match", "v1.*", "-match", "v2.*", "-exclude", "*-nightly*", "-exclude-regex", "^legacy-"},
+			cfg: &Config{
+				matchPatterns:   patterns{"v1.*", "v2.*"},
+				excludePatterns: patterns{"*-nightly*"},
+				excludeRegexps:  patterns{"^legacy-"},
+				args:            []string{},
+			},
+		},
+		{
+			args: []string{"-github"},
+			cfg:  &Config{github: true, args: []string{}},
+		},
+		{
+			args: []string{"-max-depth", "100"},
+			cfg:  &Config{maxDepth: positive{limit{value: 100, set: true}}, args: []string{}},
+		},
+		{
+			args:     []string{"-max-depth", "-1"},
+			hasError: true,
+		},
+		{
+			args:     []string{"-max-depth", "0"},
+			hasError: true,
+		},
+		{
+			args: []string{"-workspace", "-jobs", "4", "repos", "other"},
+			cfg:  &Config{workspace: true, jobs: limit{value: 4, set: true}, args: []string{"repos", "other"}},
+		},
+		{
+			args:     []string{"-workspace", "-output", "env"},
+			hasError: true,
+		},
+		{
+			args:     []string{"-workspace", "-github"},
+			hasError: true,
+		},
+		{
+			args:     []string{"-output", "yaml"},
+			hasError: true,
+		},
 		{
 			args:     []string{"-help"},
 			hasError: true,
@@ -77,6 +163,9 @@ func TestParseFlags(t *testing.T) {
 			} else {
 				test.cfg.stdout = os.Stdout
 				test.cfg.stderr = os.Stderr
+				if test.cfg.envPrefix == "" {
+					test.cfg.envPrefix = DefaultEnvPrefix
+				}
 				require.NoError(t, err)
 				assert.Equal(t, test.cfg, cfg)
 				assert.Empty(t, out)
@@ -172,6 +261,61 @@ func TestSelectFormat(t *testing.T) {
 	}
 }
 
+// newTestRepo creates a repository with a commit for each of the given tags. Empty tag names
+// create untagged commits.
+func newTestRepo(t *testing.T, tags ...string) string {
+	t.Helper()
+	return initTestRepo(t, t.TempDir(), tags...)
+}
+
+// initTestRepo creates a repository in dir with a commit for each tag. Empty tags leave the
+// commit untagged.
+func initTestRepo(t *testing.T, dir string, tags ...string) string {
+	t.Helper()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	when := time.Date(2024, 5, 13, 12, 0, 0, 0, time.UTC)
+	for i, tag := range tags {
+		signature := &object.Signature{Name: "John Doe", Email: "john@doe.org", When: when}
+		hash, err := worktree.Commit(fmt.Sprintf("commit %d", i), &git.CommitOptions{
+			Author:            signature,
+			Committer:         signature,
+			AllowEmptyCommits: true,
+		})
+		require.NoError(t, err)
+		if tag != "" {
+			_, err = repo.CreateTag(tag, hash, nil)
+			require.NoError(t, err)
+		}
+		when = when.Add(time.Minute)
+	}
+	return dir
+}
+
+func TestExitCode(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		code int
+	}{
+		{nil, exitOK},
+		{errors.New("unknown"), exitError},
+		{fmt.Errorf("%w: head commit is not tagged", version.ErrNoMatchingTag), exitNoMatchingTag},
+		{fmt.Errorf("%w: %w", version.ErrNotRepository, git.ErrRepositoryNotExists), exitNotRepository},
+		{fmt.Errorf("%w: %w", version.ErrNoHead, plumbing.ErrReferenceNotFound), exitNoHead},
+		{fmt.Errorf("%w 1.2", version.ErrInvalidTag), exitInvalidTag},
+		{fmt.Errorf("%w: q", version.ErrInvalidFormat), exitInvalidFormat},
+		{fmt.Errorf("%w \"v[\"", version.ErrInvalidPattern), exitUsage},
+		{fmt.Errorf("%w \"foo\"", version.ErrInvalidReleaseLine), exitUsage},
+		{fmt.Errorf("%w: initial version", version.ErrInvalidOption), exitUsage},
+		{fmt.Errorf("%w: no tag found", version.ErrShallowClone), exitShallowClone},
+		{&version.DepthError{Depth: 10}, exitNoMatchingTag},
+	} {
+		assert.Equal(t, test.code, exitCode(test.err))
+	}
+}
+
 func TestHandle(t *testing.T) {
 	setup := func() (*Config, *bytes.Buffer) {
 		var (
@@ -186,14 +330,14 @@ func TestHandle(t *testing.T) {
 		cfg, buf := setup()
 		assert.NoDirExists(t, "/sdf/")
 		retval := handle(cfg, "/sdf/")
-		assert.Equal(t, 1, retval)
+		assert.Equal(t, exitNotRepository, retval)
 		assert.Equal(t, "failed to open repo: repository does not exist", strings.TrimSpace(buf.String()))
 	})
 	t.Run("Path is not a git repo", func(t *testing.T) {
 		cfg, buf := setup()
 		assert.DirExists(t, "/tmp/")
 		retval := handle(cfg, "/tmp/")
-		assert.Equal(t, 1, retval)
+		assert.Equal(t, exitNotRepository, retval)
 		assert.Equal(t, "failed to open repo: repository does not exist", strings.TrimSpace(buf.String()))
 	})
 	t.Run("Meta can be set", func(t *testing.T) {
@@ -211,11 +355,286 @@ func TestHandle(t *testing.T) {
 		assert.Equal(t, 0, retval)
 		assert.True(t, strings.HasPrefix(strings.TrimSpace(buf.String()), cfg.prefix))
 	})
+	t.Run("Custom prefix selects the tags", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.prefix = "release-"
+		cfg.format = version.NoMetaFormat
+		assert.Equal(t, exitOK, handle(cfg, newTestRepo(t, "release-1.2.3", "")))
+		assert.Equal(t, "release-1.2.4-dev.1", strings.TrimSpace(buf.String()))
+	})
+	t.Run("JSON output", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.output = JSONOutput
+		cfg.setMeta = "finleap"
+		retval := handle(cfg, "")
+		assert.Equal(t, 0, retval)
+		var info Info
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &info))
+		assert.Equal(t, "finleap", info.Meta)
+		assert.Len(t, info.Hash, 40)
+		assert.Equal(t, info.Formats["full"], info.Version)
+		assert.True(t, strings.HasSuffix(info.Version, "+finleap"))
+	})
+	t.Run("Env output", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.output = ExportOutput
+		cfg.envPrefix = "APP_"
+		cfg.setMeta = "finleap"
+		retval := handle(cfg, "")
+		assert.Equal(t, 0, retval)
+		assert.Contains(t, buf.String(), "export APP_META=finleap\n")
+		assert.Contains(t, buf.String(), "export APP_DIRTY=")
+	})
+	t.Run("Pre-release and meta from CI environment", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.setPreRelease = "{{if .PullRequest}}pr.{{.PullRequest}}{{end}}"
+		cfg.setMeta = "build.{{.Build}}"
+		cfg.getenv = func(key string) string {
+			return map[string]string{
+				"GITLAB_CI":            "true",
+				"CI_MERGE_REQUEST_IID": "42",
+				"CI_PIPELINE_IID":      "7",
+			}[key]
+		}
+		retval := handle(cfg, newTestRepo(t, "1.2.3", "", ""))
+		assert.Equal(t, 0, retval)
+		assert.Equal(t, "1.2.4-pr.42.dev.2+build.7", strings.TrimSpace(buf.String()))
+	})
+	t.Run("Channel selected by branch", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.format = version.NoMetaFormat
+		cfg.channels = version.ChannelRules{{Pattern: "*", Label: "{{.Provider}}/nightly"}}
+		cfg.getenv = func(key string) string {
+			return map[string]string{"BUILDKITE": "true", "BUILDKITE_BRANCH": "main"}[key]
+		}
+		retval := handle(cfg, newTestRepo(t, "1.2.3", ""))
+		assert.Equal(t, 0, retval)
+		assert.Equal(t, "1.2.4-buildkite-nightly.1", strings.TrimSpace(buf.String()))
+	})
+	t.Run("Release line", func(t *testing.T) {
+		dir := newTestRepo(t, "1.4.0", "1.4.1", "1.5.0", "")
+		for _, test := range []struct {
+			line   string
+			target version.Target
+			retval int
+			output string
+		}{
+			{"1.4", version.Devel, 0, "1.4.2-dev.2"},
+			{"1.4.x", version.Patch, 0, "1.4.2"},
+			{"1.4", version.Minor, exitUsage, "invalid release line: target minor would leave release line 1.4"},
+			{"2.0", version.Devel, exitNoMatchingTag, "no matching tag in release line 2.0"},
+			{"foo", version.Devel, exitUsage, `invalid release line "foo": must have the form X.Y`},
+			{"auto", version.Devel, exitUsage, `invalid release line: branch "master" doesn't name a release line`},
+		} {
+			cfg, buf := setup()
+			cfg.format = version.NoMetaFormat
+			cfg.releaseLine = test.line
+			cfg.releaseTarget = test.target
+			assert.Equal(t, test.retval, handle(cfg, dir), test.line)
+			assert.Equal(t, test.output, strings.TrimSpace(buf.String()), test.line)
+		}
+
+		repo, err := git.PlainOpen(dir)
+		require.NoError(t, err)
+		worktree, err := repo.Worktree()
+		require.NoError(t, err)
+		checkout := git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("release/1.4"), Create: true}
+		require.NoError(t, worktree.Checkout(&checkout))
+		for _, test := range []struct {
+			target version.Target
+			retval int
+			output string
+		}{
+			{version.Patch, 0, "1.4.2"},
+			{version.Minor, exitUsage, "invalid release line: target minor would leave release line 1.4"},
+		} {
+			cfg, buf := setup()
+			cfg.format = version.NoMetaFormat
+			cfg.releaseLine = "auto"
+			cfg.releaseTarget = test.target
+			assert.Equal(t, test.retval, handle(cfg, dir))
+			assert.Equal(t, test.output, strings.TrimSpace(buf.String()))
+		}
+	})
+	t.Run("Exact match", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.exactMatch = true
+		assert.Equal(t, 0, handle(cfg, newTestRepo(t, "", "1.2.3")))
+		assert.Equal(t, "1.2.3", strings.TrimSpace(buf.String()))
+
+		cfg, buf = setup()
+		cfg.exactMatch = true
+		assert.Equal(t, exitNoMatchingTag, handle(cfg, newTestRepo(t, "1.2.3", "")))
+		assert.Contains(t, buf.String(), "no matching tag: head commit")
+
+		cfg, buf = setup()
+		cfg.requireAnnotated = true
+		assert.Equal(t, exitNoMatchingTag, handle(cfg, newTestRepo(t, "1.2.3")))
+		assert.Equal(t, "no matching tag: tag 1.2.3 is not annotated", strings.TrimSpace(buf.String()))
+	})
+	t.Run("Fails with invalid tag", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.matchPatterns = patterns{"*"}
+		retval := handle(cfg, newTestRepo(t, "1.2"))
+		assert.Equal(t, exitInvalidTag, retval)
+		assert.Equal(t, "invalid version tag 1.2: must contain 3 components: X.Y.Z", strings.TrimSpace(buf.String()))
+	})
+	t.Run("Fails without commits", func(t *testing.T) {
+		cfg, _ := setup()
+		dir := t.TempDir()
+		_, err := git.PlainInit(dir, false)
+		require.NoError(t, err)
+		assert.Equal(t, exitNoHead, handle(cfg, dir))
+	})
+	t.Run("Fails with invalid match pattern", func(t *testing.T) {
+		cfg, buf := setup()
+		var stdout bytes.Buffer
+		cfg.stdout = &stdout
+		cfg.matchPatterns = patterns{"v["}
+		assert.Equal(t, exitUsage, handle(cfg, newTestRepo(t, "1.2.3")))
+		assert.Empty(t, stdout.String())
+		assert.Equal(t, `invalid match pattern "v[": syntax error in pattern`, strings.TrimSpace(buf.String()))
+	})
+	t.Run("Skips short tags", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.format = version.NoMetaFormat
+		assert.Equal(t, exitOK, handle(cfg, newTestRepo(t, "1.2.3", "v2.1")))
+		assert.Equal(t, "1.2.4-dev.1", strings.TrimSpace(buf.String()))
+	})
+	t.Run("Lenient tag parser", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.lenient = true
+		cfg.output = JSONOutput
+		assert.Equal(t, exitOK, handle(cfg, newTestRepo(t, "1.2.3", "v2.1", "")))
+		var info Info
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &info))
+		assert.Equal(t, "v2.1", info.LastTag)
+		assert.Equal(t, "v2.1.1-dev.1", info.Formats["noMeta"])
+		assert.True(t, info.Normalized)
+	})
+	t.Run("Initial version", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.initialVersion = "0.1.0"
+		cfg.format = version.NoMetaFormat
+		assert.Equal(t, exitOK, handle(cfg, newTestRepo(t, "", "", "")))
+		assert.Equal(t, "0.1.1-dev.3", strings.TrimSpace(buf.String()))
+	})
+	t.Run("Initial version with a single commit", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.initialVersion = "0.1.0"
+		cfg.format = version.NoMetaFormat
+		assert.Equal(t, exitOK, handle(cfg, newTestRepo(t, "")))
+		assert.Equal(t, "0.1.1-dev.1", strings.TrimSpace(buf.String()))
+	})
+	t.Run("Initial version is ignored if a tag matches", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.initialVersion = "1.0.0"
+		cfg.format = version.NoMetaFormat
+		assert.Equal(t, exitOK, handle(cfg, newTestRepo(t, "", "0.3.0", "")))
+		assert.Equal(t, "0.3.1-dev.1", strings.TrimSpace(buf.String()))
+	})
+	t.Run("Fails with invalid initial version", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.initialVersion = "one"
+		assert.Equal(t, exitUsage, handle(cfg, newTestRepo(t, "")))
+		assert.Equal(t, `invalid option: initial version "one" isn't a version`, strings.TrimSpace(buf.String()))
+	})
+	t.Run("Tag selection", func(t *testing.T) {
+		dir := newTestRepo(t, "1.1.0-rc1")
+		repo, err := git.PlainOpen(dir)
+		require.NoError(t, err)
+		head, err := repo.Head()
+		require.NoError(t, err)
+		_, err = repo.CreateTag("1.0.0", head.Hash(), nil)
+		require.NoError(t, err)
+
+		cfg, buf := setup()
+		assert.Equal(t, exitOK, handle(cfg, dir))
+		assert.Equal(t, "1.1.0-rc1", strings.TrimSpace(buf.String()))
+
+		cfg, buf = setup()
+		cfg.selection = version.SelectRelease
+		assert.Equal(t, exitOK, handle(cfg, dir))
+		assert.Equal(t, "1.0.0", strings.TrimSpace(buf.String()))
+	})
+	t.Run("Shallow clone", func(t *testing.T) {
+		dir := newTestRepo(t, "1.0.0", "", "")
+		repo, err := git.PlainOpen(dir)
+		require.NoError(t, err)
+		head, err := repo.Head()
+		require.NoError(t, err)
+		require.NoError(t, repo.Storer.SetShallow([]plumbing.Hash{head.Hash()}))
+
+		cfg, buf := setup()
+		assert.Equal(t, exitShallowClone, handle(cfg, dir))
+		assert.Contains(t, buf.String(), "fetch more history")
+
+		cfg, buf = setup()
+		var stdout bytes.Buffer
+		cfg.stdout = &stdout
+		cfg.shallowFallback = true
+		cfg.format = version.NoMetaFormat
+		assert.Equal(t, exitOK, handle(cfg, dir))
+		assert.Equal(t, "1.0.1-dev.1\n", stdout.String())
+		assert.Contains(t, buf.String(), "Warning: shallow clone")
+	})
+	t.Run("Git backend", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.backend = GitBackend
+		cfg.format = version.NoMetaFormat
+		assert.Equal(t, exitOK, handle(cfg, newTestRepo(t, "1.2.3", "", "")))
+		assert.Equal(t, "1.2.4-dev.2", strings.TrimSpace(buf.String()))
+	})
+	t.Run("Search limits", func(t *testing.T) {
+		dir := newTestRepo(t, "1.2.3", "", "", "")
+
+		cfg, buf := setup()
+		cfg.maxDepth = positive{limit{value: 2, set: true}}
+		assert.Equal(t, exitNoMatchingTag, handle(cfg, dir))
+		assert.Contains(t, buf.String(), "no tag within 2 commits")
+
+		cfg, buf = setup()
+		cfg.maxDepth = positive{limit{value: 3, set: true}}
+		cfg.format = version.NoMetaFormat
+		assert.Equal(t, exitOK, handle(cfg, dir))
+		assert.Equal(t, "1.2.4-dev.3", strings.TrimSpace(buf.String()))
+	})
+	t.Run("Cache", func(t *testing.T) {
+		dir := newTestRepo(t, "1.2.3", "")
+		for range 2 {
+			cfg, buf := setup()
+			cfg.cache = true
+			cfg.format = version.NoMetaFormat
+			assert.Equal(t, exitOK, handle(cfg, dir))
+			assert.Equal(t, "1.2.4-dev.1", strings.TrimSpace(buf.String()))
+		}
+		assert.FileExists(t, filepath.Join(dir, ".git", "git-semver-cache.json"))
+	})
+	t.Run("Custom tag parser", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.parseRegex = `release-(?P<major>\d+)\.(?P<minor>\d+)\.(?P<patch>\d+)`
+		cfg.format = version.NoMetaFormat
+		assert.Equal(t, exitOK, handle(cfg, newTestRepo(t, "release-2023.4.1", "")))
+		assert.Equal(t, "2023.4.2-dev.1", strings.TrimSpace(buf.String()))
+	})
+	t.Run("Fails with invalid tag parser", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.parseRegex = `release-(?P<minor>\d+)`
+		assert.Equal(t, exitUsage, handle(cfg, newTestRepo(t, "1.2.3")))
+		assert.Contains(t, buf.String(), "missing named group major")
+	})
+	t.Run("Fails with invalid template", func(t *testing.T) {
+		cfg, buf := setup()
+		cfg.setMeta = "{{.Build"
+		retval := handle(cfg, "")
+		assert.Equal(t, 1, retval)
+		assert.Contains(t, buf.String(), "invalid meta template")
+	})
 	t.Run("Fails with invalid format", func(t *testing.T) {
 		cfg, buf := setup()
 		cfg.format = "a.b.c"
 		retval := handle(cfg, "")
-		assert.Equal(t, 1, retval)
+		assert.Equal(t, exitInvalidFormat, retval)
 		assert.Equal(t, fmt.Sprintf("invalid format: %s", cfg.format), strings.TrimSpace(buf.String()))
 	})
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mdomke/git-semver/v6/version"
+)
+
+// Monotonic selects what happens if the computed version is not greater than the highest tag
+// in the repository.
+type Monotonic int
+
+const (
+	MonotonicOff  Monotonic = iota // no check is performed
+	MonotonicFail                  // fail if the version is not the greatest
+	MonotonicBump                  // bump the version above the highest tag
+)
+
+func (m *Monotonic) String() string {
+	switch *m {
+	case MonotonicOff:
+		return "off"
+	case MonotonicFail:
+		return "fail"
+	case MonotonicBump:
+		return "bump"
+	default:
+		panic(fmt.Errorf("unexpected monotonic mode %v", *m))
+	}
+}
+
+func (m *Monotonic) Set(value string) error {
+	switch value {
+	case "off":
+		*m = MonotonicOff
+	case "fail":
+		*m = MonotonicFail
+	case "bump":
+		*m = MonotonicBump
+	default:
+		return errors.New(`parse error`)
+	}
+	return nil
+}
+
+// ensureMonotonic compares v with the highest matching tag of the repository. A version equal to
+// the highest tag is only accepted if the head commit is tagged with it.
+func ensureMonotonic(
+	mode Monotonic,
+	ver version.Version,
+	head *version.RepoHead,
+	parser version.Parser,
+) (version.Version, error) {
+	if mode == MonotonicOff || head.HighestTag == "" {
+		return ver, nil
+	}
+	highest, err := version.NewFromHeadWithParser(&version.RepoHead{LastTag: head.HighestTag}, parser)
+	if err != nil {
+		return ver, err
+	}
+	switch cmp := ver.Compare(highest); {
+	case cmp > 0:
+		return ver, nil
+	case cmp == 0 && head.CommitsSinceTag == 0 && head.LastTag == head.HighestTag:
+		return ver, nil
+	case mode == MonotonicBump:
+		return ver.BumpAbove(highest), nil
+	default:
+		return ver, fmt.Errorf("version %s is not greater than the highest tag %s", ver, head.HighestTag)
+	}
+}
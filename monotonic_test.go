@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mdomke/git-semver/v6/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonotonicToString(t *testing.T) {
+	assert.PanicsWithError(t, "unexpected monotonic mode 8", func() {
+		mode := Monotonic(8)
+		_ = mode.String()
+	})
+
+	mode := MonotonicOff
+	assert.Equal(t, "off", mode.String())
+
+	mode = MonotonicFail
+	assert.Equal(t, "fail", mode.String())
+
+	mode = MonotonicBump
+	assert.Equal(t, "bump", mode.String())
+}
+
+func TestParseMonotonic(t *testing.T) {
+	var mode Monotonic
+	require.EqualError(t, mode.Set("foo"), "parse error")
+
+	require.NoError(t, mode.Set("fail"))
+	assert.Equal(t, MonotonicFail, mode)
+
+	require.NoError(t, mode.Set("bump"))
+	assert.Equal(t, MonotonicBump, mode)
+
+	require.NoError(t, mode.Set("off"))
+	assert.Equal(t, MonotonicOff, mode)
+}
+
+func TestEnsureMonotonic(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		mode    Monotonic
+		head    version.RepoHead
+		target  version.Target
+		version string
+		err     string
+	}{
+		{
+			desc:    "Disabled",
+			mode:    MonotonicOff,
+			head:    version.RepoHead{LastTag: "1.2.3", HighestTag: "1.3.0", CommitsSinceTag: 2, Hash: "fcf2c8fa"},
+			version: "1.2.4-dev.2+fcf2c8fa",
+		},
+		{
+			desc:    "Greatest version",
+			mode:    MonotonicFail,
+			head:    version.RepoHead{LastTag: "1.3.0", HighestTag: "1.3.0", CommitsSinceTag: 2, Hash: "fcf2c8fa"},
+			version: "1.3.1-dev.2+fcf2c8fa",
+		},
+		{
+			desc:    "Head tagged with highest tag",
+			mode:    MonotonicFail,
+			head:    version.RepoHead{LastTag: "1.3.0", HighestTag: "1.3.0"},
+			version: "1.3.0",
+		},
+		{
+			desc: "Lower version fails",
+			mode: MonotonicFail,
+			head: version.RepoHead{LastTag: "1.2.3", HighestTag: "1.3.0", CommitsSinceTag: 2, Hash: "fcf2c8fa"},
+			err:  "version 1.2.4-dev.2+fcf2c8fa is not greater than the highest tag 1.3.0",
+		},
+		{
+			desc:   "Equal version fails",
+			mode:   MonotonicFail,
+			head:   version.RepoHead{LastTag: "1.2.9", HighestTag: "1.3.0", CommitsSinceTag: 2, Hash: "fcf2c8fa"},
+			target: version.Minor,
+			err:    "version 1.3.0 is not greater than the highest tag 1.3.0",
+		},
+		{
+			desc:    "Lower version is bumped",
+			mode:    MonotonicBump,
+			head:    version.RepoHead{LastTag: "v1.2.3", HighestTag: "v1.3.0", CommitsSinceTag: 2, Hash: "fcf2c8fa"},
+			version: "v1.3.1-dev.2+fcf2c8fa",
+		},
+		{
+			desc:    "Equal version is bumped",
+			mode:    MonotonicBump,
+			head:    version.RepoHead{LastTag: "1.2.9", HighestTag: "1.3.0", CommitsSinceTag: 2, Hash: "fcf2c8fa"},
+			target:  version.Minor,
+			version: "1.3.1",
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			ver, err := version.NewFromHead(&test.head, "")
+			require.NoError(t, err)
+			ver, err = ensureMonotonic(test.mode, ver.BumpTo(test.target), &test.head, version.PrefixParser(""))
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.version, ver.String())
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mdomke/git-semver/v6/ci"
+	"github.com/mdomke/git-semver/v6/version"
+)
+
+// Output selects how the computed version is printed.
+type Output int
+
+const (
+	TextOutput   Output = iota // prints the formatted version only
+	JSONOutput                 // prints a JSON object with all computed fields
+	EnvOutput                  // prints all computed fields as dotenv lines
+	ExportOutput               // prints all computed fields as shell export statements
+)
+
+// DefaultEnvPrefix is prepended to the variable names of the env and export outputs.
+const DefaultEnvPrefix = "GIT_SEMVER_"
+
+func (o *Output) String() string {
+	switch *o {
+	case TextOutput:
+		return "text"
+	case JSONOutput:
+		return "json"
+	case EnvOutput:
+		return "env"
+	case ExportOutput:
+		return "export"
+	default:
+		panic(fmt.Errorf("unexpected output %v", *o))
+	}
+}
+
+func (o *Output) Set(value string) error {
+	switch value {
+	case "text":
+		*o = TextOutput
+	case "json":
+		*o = JSONOutput
+	case "env":
+		*o = EnvOutput
+	case "export":
+		*o = ExportOutput
+	default:
+		return errors.New(`parse error`)
+	}
+	return nil
+}
+
+// Info holds every field that git-semver computes for a repository.
+type Info struct {
+	Version         string            `json:"version"`
+	Prefix          string            `json:"prefix"`
+	Major           int               `json:"major"`
+	Minor           int               `json:"minor"`
+	Patch           int               `json:"patch"`
+	PreRelease      string            `json:"preRelease"`
+	Meta            string            `json:"meta"`
+	LastTag         string            `json:"lastTag"`
+	HighestTag      string            `json:"highestTag"`
+	Annotated       bool              `json:"annotated"`
+	Signed          bool              `json:"signed"`
+	Normalized      bool              `json:"normalized"`
+	CommitsSinceTag int               `json:"commitsSinceTag"`
+	Hash            string            `json:"hash"`
+	Short           string            `json:"short"`
+	Branch          string            `json:"branch"`
+	PullRequest     string            `json:"pullRequest"`
+	Build           string            `json:"build"`
+	CI              string            `json:"ci"`
+	Dirty           bool              `json:"dirty"`
+	Shallow         bool              `json:"shallow"`
+	Formats         map[string]string `json:"formats"`
+}
+
+type shorthandFormat struct {
+	name    string
+	envName string
+	format  string
+}
+
+var shorthandFormats = []shorthandFormat{
+	{"full", "FULL", version.FullFormat},
+	{"noMeta", "NO_META", version.NoMetaFormat},
+	{"noPre", "NO_PRE", version.NoPreFormat},
+	{"noPatch", "NO_PATCH", version.NoPatchFormat},
+	{"noMinor", "NO_MINOR", version.NoMinorFormat},
+}
+
+func newInfo(formatted string, ver version.Version, head *version.RepoHead, env ci.Env) Info {
+	info := Info{
+		Version:         formatted,
+		Prefix:          ver.Prefix,
+		Major:           ver.Major,
+		Minor:           ver.Minor,
+		Patch:           ver.Patch,
+		PreRelease:      ver.PreRelease(),
+		Meta:            ver.Meta,
+		LastTag:         head.LastTag,
+		HighestTag:      head.HighestTag,
+		Annotated:       head.Annotated,
+		Signed:          head.Signed,
+		Normalized:      ver.Normalized,
+		CommitsSinceTag: head.CommitsSinceTag,
+		Hash:            head.Hash,
+		Short:           shortHash(head.Hash),
+		Branch:          head.Branch,
+		PullRequest:     env.PullRequest,
+		Build:           env.Build,
+		CI:              env.Provider,
+		Dirty:           head.Dirty,
+		Shallow:         head.Shallow,
+		Formats:         make(map[string]string, len(shorthandFormats)),
+	}
+	for _, f := range shorthandFormats {
+		s, err := ver.Format(f.format)
+		if err != nil {
+			continue
+		}
+		info.Formats[f.name] = s
+	}
+	return info
+}
+
+// shortHash abbreviates a commit hash to the length used in the build metadata.
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
+
+type envVar struct {
+	name  string
+	value string
+}
+
+// env returns the fields of the info as environment variables in a stable order.
+func (i Info) env() []envVar {
+	vars := []envVar{
+		{"VERSION", i.Version},
+		{"PREFIX", i.Prefix},
+		{"MAJOR", strconv.Itoa(i.Major)},
+		{"MINOR", strconv.Itoa(i.Minor)},
+		{"PATCH", strconv.Itoa(i.Patch)},
+		{"PRE_RELEASE", i.PreRelease},
+		{"META", i.Meta},
+		{"LAST_TAG", i.LastTag},
+		{"HIGHEST_TAG", i.HighestTag},
+		{"ANNOTATED", strconv.FormatBool(i.Annotated)},
+		{"SIGNED", strconv.FormatBool(i.Signed)},
+		{"NORMALIZED", strconv.FormatBool(i.Normalized)},
+		{"COMMITS_SINCE_TAG", strconv.Itoa(i.CommitsSinceTag)},
+		{"HASH", i.Hash},
+		{"SHORT", i.Short},
+		{"BRANCH", i.Branch},
+		{"PULL_REQUEST", i.PullRequest},
+		{"BUILD", i.Build},
+		{"CI", i.CI},
+		{"DIRTY", strconv.FormatBool(i.Dirty)},
+		{"SHALLOW", strconv.FormatBool(i.Shallow)},
+	}
+	for _, f := range shorthandFormats {
+		vars = append(vars, envVar{f.envName, i.Formats[f.name]})
+	}
+	return vars
+}
+
+func writeInfo(cfg *Config, info Info) error {
+	switch cfg.output {
+	case JSONOutput:
+		return writeJSON(cfg.stdout, info)
+	case EnvOutput:
+		return writeEnv(cfg.stdout, info, cfg.envPrefix, false)
+	case ExportOutput:
+		return writeEnv(cfg.stdout, info, cfg.envPrefix, true)
+	default:
+		_, err := fmt.Fprintln(cfg.stdout, info.Version)
+		return err
+	}
+}
+
+func writeJSON(w io.Writer, info Info) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(info)
+}
+
+// writeEnv prints the info as NAME=value lines. The values are not quoted so that the
+// output can be consumed by dotenv parsers like docker's --env-file or GitLab's dotenv reports.
+// With export set, each line is prefixed with export and the value is quoted for the shell.
+func writeEnv(out io.Writer, info Info, prefix string, export bool) error {
+	for _, variable := range info.env() {
+		var err error
+		if export {
+			_, err = fmt.Fprintf(out, "export %s%s=%s\n", prefix, variable.name, shellQuote(variable.value))
+		} else {
+			_, err = fmt.Fprintf(out, "%s%s=%s\n", prefix, variable.name, variable.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func shellQuote(value string) string {
+	if value != "" && strings.IndexFunc(value, func(char rune) bool {
+		return !strings.ContainsRune(shellSafe, char)
+	}) < 0 {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+const shellSafe = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789@%+=:,./-_"
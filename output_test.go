@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mdomke/git-semver/v6/ci"
+	"github.com/mdomke/git-semver/v6/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputToString(t *testing.T) {
+	assert.PanicsWithError(t, "unexpected output 8", func() {
+		output := Output(8)
+		_ = output.String()
+	})
+
+	output := TextOutput
+	assert.Equal(t, "text", output.String())
+
+	output = JSONOutput
+	assert.Equal(t, "json", output.String())
+
+	output = EnvOutput
+	assert.Equal(t, "env", output.String())
+
+	output = ExportOutput
+	assert.Equal(t, "export", output.String())
+}
+
+func TestParseOutput(t *testing.T) {
+	var output Output
+	require.EqualError(t, output.Set("foo"), "parse error")
+
+	require.NoError(t, output.Set("json"))
+	assert.Equal(t, JSONOutput, output)
+
+	require.NoError(t, output.Set("env"))
+	assert.Equal(t, EnvOutput, output)
+
+	require.NoError(t, output.Set("export"))
+	assert.Equal(t, ExportOutput, output)
+
+	require.NoError(t, output.Set("text"))
+	assert.Equal(t, TextOutput, output)
+}
+
+func TestNewInfo(t *testing.T) {
+	head := &version.RepoHead{
+		LastTag:         "v1.2.3",
+		HighestTag:      "v2.0.0",
+		CommitsSinceTag: 4,
+		Hash:            "fcf2c8fa5b54d7a1bd16e4d69c0bc4cc87db9b1e",
+		Dirty:           true,
+	}
+	v, err := version.NewFromHead(head, "")
+	require.NoError(t, err)
+	v = v.BumpTo(version.Devel)
+
+	info := newInfo("v1.2.4-dev.4", v, head, ci.Env{Provider: "gitlab", PullRequest: "7", Build: "9"})
+	assert.Equal(t, Info{
+		Version:         "v1.2.4-dev.4",
+		Prefix:          "v",
+		Major:           1,
+		Minor:           2,
+		Patch:           4,
+		PreRelease:      "dev.4",
+		Meta:            "fcf2c8fa",
+		LastTag:         "v1.2.3",
+		HighestTag:      "v2.0.0",
+		CommitsSinceTag: 4,
+		Hash:            "fcf2c8fa5b54d7a1bd16e4d69c0bc4cc87db9b1e",
+		Short:           "fcf2c8fa",
+		PullRequest:     "7",
+		Build:           "9",
+		CI:              "gitlab",
+		Dirty:           true,
+		Formats: map[string]string{
+			"full":    "v1.2.4-dev.4+fcf2c8fa",
+			"noMeta":  "v1.2.4-dev.4",
+			"noPre":   "v1.2.4",
+			"noPatch": "v1.2",
+			"noMinor": "v1",
+		},
+	}, info)
+
+	var buf bytes.Buffer
+	require.NoError(t, writeJSON(&buf, info))
+	assert.Contains(t, buf.String(), `"commitsSinceTag": 4`)
+	assert.Contains(t, buf.String(), `"noPatch": "v1.2"`)
+}
+
+func TestWriteEnv(t *testing.T) {
+	info := Info{
+		Version:         "1.2.4-dev.4",
+		Major:           1,
+		Minor:           2,
+		Patch:           4,
+		PreRelease:      "dev.4",
+		LastTag:         "1.2.3",
+		CommitsSinceTag: 4,
+		Hash:            "fcf2c8fa0d3b6b9a6c3e4f5a6b7c8d9e0f1a2b3c",
+		Short:           "fcf2c8fa",
+		Branch:          "main",
+		Formats:         map[string]string{"noPatch": "1.2"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeEnv(&buf, info, DefaultEnvPrefix, false))
+	assert.Equal(t, `GIT_SEMVER_VERSION=1.2.4-dev.4
+GIT_SEMVER_PREFIX=
+GIT_SEMVER_MAJOR=1
+GIT_SEMVER_MINOR=2
+GIT_SEMVER_PATCH=4
+GIT_SEMVER_PRE_RELEASE=dev.4
+GIT_SEMVER_META=
+GIT_SEMVER_LAST_TAG=1.2.3
+GIT_SEMVER_HIGHEST_TAG=
+GIT_SEMVER_ANNOTATED=false
+GIT_SEMVER_SIGNED=false
+GIT_SEMVER_NORMALIZED=false
+GIT_SEMVER_COMMITS_SINCE_TAG=4
+GIT_SEMVER_HASH=fcf2c8fa0d3b6b9a6c3e4f5a6b7c8d9e0f1a2b3c
+GIT_SEMVER_SHORT=fcf2c8fa
+GIT_SEMVER_BRANCH=main
+GIT_SEMVER_PULL_REQUEST=
+GIT_SEMVER_BUILD=
+GIT_SEMVER_CI=
+GIT_SEMVER_DIRTY=false
+GIT_SEMVER_SHALLOW=false
+GIT_SEMVER_FULL=
+GIT_SEMVER_NO_META=
+GIT_SEMVER_NO_PRE=
+GIT_SEMVER_NO_PATCH=1.2
+GIT_SEMVER_NO_MINOR=
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeEnv(&buf, info, "", true))
+	assert.Contains(t, buf.String(), "export VERSION=1.2.4-dev.4\n")
+	assert.Contains(t, buf.String(), "export PREFIX=''\n")
+}
+
+func TestShellQuote(t *testing.T) {
+	for _, test := range []struct {
+		in  string
+		out string
+	}{
+		{"1.2.3-dev.4+fcf2c8fa", "1.2.3-dev.4+fcf2c8fa"},
+		{"", "''"},
+		{"feature/foo bar", "'feature/foo bar'"},
+		{"it's", `'it'\''s'`},
+	} {
+		assert.Equal(t, test.out, shellQuote(test.in))
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/mdomke/git-semver/v6/ci"
+)
+
+// templateData is available to the -set-pre and -set-meta templates.
+type templateData struct {
+	ci.Env
+	Commits int
+	Hash    string
+}
+
+func expandTemplate(name, text string, data templateData) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	var b strings.Builder
+	if err = tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to expand %s template: %w", name, err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mdomke/git-semver/v6/ci"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	data := templateData{
+		Env:     ci.Env{Provider: "github", Branch: "main", PullRequest: "42", Build: "17"},
+		Commits: 5,
+		Hash:    "fcf2c8fa",
+	}
+	for _, test := range []struct {
+		text     string
+		expected string
+	}{
+		{"finleap", "finleap"},
+		{"pr.{{.PullRequest}}", "pr.42"},
+		{"build.{{.Build}}.{{.Hash}}", "build.17.fcf2c8fa"},
+		{"{{if .PullRequest}}pr.{{.PullRequest}}{{end}}", "pr.42"},
+		{"{{.Provider}}.{{.Commits}}", "github.5"},
+	} {
+		s, err := expandTemplate("meta", test.text, data)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, s)
+	}
+
+	_, err := expandTemplate("meta", "{{.Build", data)
+	require.ErrorContains(t, err, "invalid meta template")
+
+	_, err = expandTemplate("pre", "{{.Unknown}}", data)
+	require.ErrorContains(t, err, "failed to expand pre template")
+}
@@ -0,0 +1,335 @@
+package version
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	commitgraphfmt "github.com/go-git/go-git/v5/plumbing/format/commitgraph/v2"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// Backend provides access to the history of a git repository. [GitDescribe] is built on top of
+// it, so that repositories can either be read with go-git ([OpenGoGit]) or with the local git
+// binary ([OpenGitCLI]).
+type Backend interface {
+	// Head returns the hash of the head commit and the name of the checked-out branch, which is
+	// empty if the head is detached. The returned error wraps [ErrNoHead].
+	Head() (hash string, branch string, err error)
+	// Resolve returns the hash of the commit the revision points to, e.g. a branch, tag or
+	// abbreviated hash. The returned error wraps [ErrUnknownRevision].
+	Resolve(revision string) (hash string, err error)
+	// Tags returns the tags whose names pass the filter together with the commits they point to.
+	// It returns ctx.Err() once ctx is done.
+	Tags(ctx context.Context, filter func(tagName string) bool) ([]TagRef, error)
+	// Walk calls visit for every commit reachable from hash, newest first. It stops without an
+	// error if visit returns [ErrStopWalk] and with ctx.Err() once ctx is done. The parents of
+	// shallow commits are not visited.
+	Walk(ctx context.Context, hash string, visit func(Commit) error) error
+	// Shallow returns the hashes of the boundary commits of a shallow clone.
+	Shallow() ([]string, error)
+	// Dirty reports whether tracked files have uncommitted changes.
+	Dirty() (bool, error)
+}
+
+// OpenBackend opens the [Backend] for the repository at path. The returned error wraps
+// [ErrNotRepository] if path isn't inside a repository.
+type OpenBackend = func(path string) (Backend, error)
+
+// ErrStopWalk can be returned by the visit function of [Backend.Walk] to end the walk.
+var ErrStopWalk = errors.New("stop walk")
+
+// TagRef is a tag as listed by a [Backend]. Err is set if the tag doesn't point to a commit, in
+// which case it is ignored.
+type TagRef struct {
+	Tag
+	Commit string
+	Err    error
+}
+
+// Commit is a commit as visited by [Backend.Walk].
+type Commit struct {
+	Hash    string
+	Parents []string
+	When    time.Time
+}
+
+// WithBackend selects the backend that is used to read the repository. It defaults to [OpenGoGit].
+func WithBackend(open OpenBackend) Option {
+	return func(opts *options) {
+		opts.open = open
+	}
+}
+
+// closeBackend releases the resources of backends that implement [io.Closer].
+func closeBackend(backend Backend) {
+	if closer, ok := backend.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+type goGitBackend struct {
+	repo    *git.Repository
+	storage *filesystem.Storage
+	nodes   commitgraph.CommitNodeIndex
+	graph   commitgraphfmt.Index
+}
+
+// OpenGoGit opens the repository at path or any of its parent directories with go-git. The
+// packfiles and the commit-graph are kept open until the backend is closed, see [io.Closer].
+func OpenGoGit(path string) (Backend, error) {
+	openOpts := git.PlainOpenOptions{DetectDotGit: true}
+	repo, err := git.PlainOpenWithOptions(path, &openOpts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotRepository, err)
+	}
+	storage, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return newGoGitBackend(repo), nil
+	}
+	// By default go-git opens the packfile again for every object that is read, which dominates
+	// the time needed to peel thousands of tags.
+	storage = filesystem.NewStorageWithOptions(
+		storage.Filesystem(),
+		cache.NewObjectLRUDefault(),
+		filesystem.Options{KeepDescriptors: true},
+	)
+	var worktree billy.Filesystem
+	if tree, err := repo.Worktree(); err == nil {
+		worktree = tree.Filesystem
+	}
+	repo, err = git.Open(storage, worktree)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotRepository, err)
+	}
+	backend := newGoGitBackend(repo)
+	backend.storage = storage
+	return backend, nil
+}
+
+// Close releases the packfiles and the commit-graph that were kept open.
+func (b goGitBackend) Close() error {
+	var errs []error
+	if b.graph != nil {
+		errs = append(errs, b.graph.Close())
+	}
+	if b.storage != nil {
+		errs = append(errs, b.storage.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (b goGitBackend) Head() (string, string, error) {
+	head, err := b.repo.Head()
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrNoHead, err)
+	}
+	if !head.Name().IsBranch() {
+		return head.Hash().String(), "", nil
+	}
+	return head.Hash().String(), head.Name().Short(), nil
+}
+
+func (b goGitBackend) Resolve(revision string) (string, error) {
+	hash, err := b.repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return "", fmt.Errorf("%w %s: %w", ErrUnknownRevision, revision, err)
+	}
+	commit, err := b.repo.CommitObject(*hash)
+	if err != nil {
+		return "", fmt.Errorf("%w %s: %w", ErrUnknownRevision, revision, err)
+	}
+	return commit.Hash.String(), nil
+}
+
+// Tags reads every tag object only once. Annotated tags are peeled with the target of the tag
+// object, so that the tagged commit isn't loaded. Only nested tags require further tag objects. The commits of lightweight
+// tags are looked up in the commit-graph if possible.
+func (b goGitBackend) Tags(ctx context.Context, filter func(tagName string) bool) ([]TagRef, error) {
+	tags, err := b.repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+	var result []TagRef
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// ReferenceName.Short is expensive, because it tries all the rules of git rev-parse.
+		name := strings.TrimPrefix(ref.Name().String(), "refs/tags/")
+		if !filter(name) {
+			return nil
+		}
+		tagRef := TagRef{Tag: Tag{Name: name}}
+		obj, err := b.repo.Storer.EncodedObject(plumbing.AnyObject, ref.Hash())
+		if err != nil {
+			tagRef.Err = err
+			result = append(result, tagRef)
+			return nil
+		}
+		switch obj.Type() {
+		case plumbing.TagObject:
+			tag, err := object.DecodeTag(b.repo.Storer, obj)
+			if err != nil {
+				return err
+			}
+			tagRef.Annotated = true
+			tagRef.When = tag.Tagger.When
+			tagRef.Signed = tag.PGPSignature != ""
+			tagRef.Commit, tagRef.Err = peel(b.repo.Storer, tag)
+		case plumbing.CommitObject:
+			// The commit time is read from the commit-graph if the commit is part of it.
+			node, err := b.nodes.Get(ref.Hash())
+			if err != nil {
+				return err
+			}
+			tagRef.Commit = ref.Hash().String()
+			tagRef.When = node.CommitTime()
+		default:
+			tagRef.Err = fmt.Errorf("%s doesn't point to a commit", ref.Hash())
+		}
+		result = append(result, tagRef)
+		return nil
+	})
+	return result, err
+}
+
+// peel returns the hash of the commit an annotated tag points to. Nested tags, i.e. tags of
+// tags, are followed until a commit is reached.
+func peel(objects storer.EncodedObjectStorer, tag *object.Tag) (string, error) {
+	target := tag
+	for target.TargetType == plumbing.TagObject {
+		next, err := object.GetTag(objects, target.Target)
+		if err != nil {
+			return "", err
+		}
+		target = next
+	}
+	if target.TargetType != plumbing.CommitObject {
+		return "", fmt.Errorf("%s doesn't point to a commit", tag.Hash)
+	}
+	return target.Target.String(), nil
+}
+
+// Walk visits the commits ordered by their committer time. Unlike the log of go-git, it doesn't
+// try to load the missing parents of shallow commits.
+func (b goGitBackend) Walk(ctx context.Context, hash string, visit func(Commit) error) error {
+	shallow, err := b.Shallow()
+	if err != nil {
+		return err
+	}
+	boundary := make(map[string]bool, len(shallow))
+	for _, shallowHash := range shallow {
+		boundary[shallowHash] = true
+	}
+	start, err := b.nodes.Get(plumbing.NewHash(hash))
+	if err != nil {
+		return err
+	}
+	queue := commitQueue{start}
+	seen := map[plumbing.Hash]bool{start.ID(): true}
+	for queue.Len() > 0 {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		node := heap.Pop(&queue).(commitgraph.CommitNode) // nolint: forcetypeassert
+		visited := Commit{Hash: node.ID().String(), When: node.CommitTime()}
+		for _, parent := range node.ParentHashes() {
+			visited.Parents = append(visited.Parents, parent.String())
+		}
+		if err = visit(visited); errors.Is(err, ErrStopWalk) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if boundary[visited.Hash] {
+			continue
+		}
+		for _, parent := range node.ParentHashes() {
+			if seen[parent] {
+				continue
+			}
+			seen[parent] = true
+			parentNode, err := b.nodes.Get(parent)
+			if err != nil {
+				return err
+			}
+			heap.Push(&queue, parentNode)
+		}
+	}
+	return nil
+}
+
+func (b goGitBackend) Shallow() ([]string, error) {
+	hashes, err := b.repo.Storer.Shallow()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		result = append(result, hash.String())
+	}
+	return result, nil
+}
+
+func (b goGitBackend) GitDir() (string, error) {
+	storage, ok := b.repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return "", errors.New("the repository isn't stored on disk")
+	}
+	return filepath.Abs(storage.Filesystem().Root())
+}
+
+func (b goGitBackend) Dirty() (bool, error) {
+	worktree, err := b.repo.Worktree()
+	if errors.Is(err, git.ErrIsBareRepository) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return false, err
+	}
+	for _, file := range status {
+		if file.Worktree == git.Untracked {
+			continue
+		}
+		if file.Staging != git.Unmodified || file.Worktree != git.Unmodified {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// commitQueue is a heap of commits with the newest committer time first.
+type commitQueue []commitgraph.CommitNode
+
+func (q commitQueue) Len() int { return len(q) }
+
+func (q commitQueue) Less(i, j int) bool {
+	return q[i].CommitTime().After(q[j].CommitTime())
+}
+
+func (q commitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *commitQueue) Push(x any) { *q = append(*q, x.(commitgraph.CommitNode)) } // nolint: forcetypeassert
+
+func (q *commitQueue) Pop() any {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
@@ -0,0 +1,143 @@
+package version
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openBackend(t *testing.T, backend Option, dir string) (Backend, error) {
+	t.Helper()
+	var opts options
+	backend(&opts)
+	return opts.open(dir)
+}
+
+func TestBackendErrors(t *testing.T) {
+	testBackends(t, func(t *testing.T, backend Option) {
+		dir := t.TempDir()
+		_, err := openBackend(t, backend, dir)
+		require.ErrorIs(t, err, ErrNotRepository)
+
+		_, err = git.PlainInit(dir, false)
+		require.NoError(t, err)
+		repo, err := openBackend(t, backend, dir)
+		require.NoError(t, err)
+		_, _, err = repo.Head()
+		require.ErrorIs(t, err, ErrNoHead)
+	})
+}
+
+func TestBackendHistory(t *testing.T) {
+	testBackends(t, func(t *testing.T, backend Option) {
+		dir := t.TempDir()
+		repo, err := git.PlainInit(dir, false)
+		require.NoError(t, err)
+		worktree, err := repo.Worktree()
+		require.NoError(t, err)
+
+		signature := &object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Unix(1715601600, 0)}
+		var hashes []plumbing.Hash
+		for range 3 {
+			signature.When = signature.When.Add(time.Minute)
+			opts := git.CommitOptions{Author: signature, Committer: signature, AllowEmptyCommits: true}
+			hash, err := worktree.Commit("commit", &opts)
+			require.NoError(t, err)
+			hashes = append(hashes, hash)
+		}
+		_, err = repo.CreateTag("v1.0.0", hashes[0], nil)
+		require.NoError(t, err)
+		signature.When = signature.When.Add(time.Minute)
+		annotated, err := repo.CreateTag("v1.1.0", hashes[1], &git.CreateTagOptions{Tagger: signature, Message: "release"})
+		require.NoError(t, err)
+		_, err = repo.CreateTag("v1.2.0", annotated.Hash(), &git.CreateTagOptions{Tagger: signature, Message: "nested"})
+		require.NoError(t, err)
+		blob := repo.Storer.NewEncodedObject()
+		blob.SetType(plumbing.BlobObject)
+		blobHash, err := repo.Storer.SetEncodedObject(blob)
+		require.NoError(t, err)
+		require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference("refs/tags/v9.9.9", blobHash)))
+		_, err = repo.CreateTag("latest", hashes[2], nil)
+		require.NoError(t, err)
+
+		history, err := openBackend(t, backend, dir)
+		require.NoError(t, err)
+
+		hash, branch, err := history.Head()
+		require.NoError(t, err)
+		assert.Equal(t, hashes[2].String(), hash)
+		assert.Equal(t, "master", branch)
+
+		for _, revision := range []string{"v1.1.0", "master~1", hashes[1].String()[:8]} {
+			resolved, err := history.Resolve(revision)
+			require.NoError(t, err)
+			assert.Equal(t, hashes[1].String(), resolved, revision)
+		}
+		_, err = history.Resolve("v9.9.9")
+		require.ErrorIs(t, err, ErrUnknownRevision)
+		_, err = history.Resolve("unknown")
+		require.ErrorIs(t, err, ErrUnknownRevision)
+
+		tags, err := history.Tags(context.Background(), func(tagName string) bool { return tagName != "latest" })
+		require.NoError(t, err)
+		require.Len(t, tags, 4)
+		assert.Equal(t, "v1.0.0", tags[0].Name)
+		assert.Equal(t, hashes[0].String(), tags[0].Commit)
+		assert.False(t, tags[0].Annotated)
+		assert.Equal(t, time.Unix(1715601660, 0).Unix(), tags[0].When.Unix())
+		assert.Equal(t, "v1.1.0", tags[1].Name)
+		assert.Equal(t, hashes[1].String(), tags[1].Commit)
+		assert.True(t, tags[1].Annotated)
+		assert.Equal(t, signature.When.Unix(), tags[1].When.Unix())
+		assert.Equal(t, "v1.2.0", tags[2].Name)
+		require.NoError(t, tags[2].Err)
+		assert.Equal(t, hashes[1].String(), tags[2].Commit, "nested tags are peeled to the commit")
+		assert.True(t, tags[2].Annotated)
+		assert.Equal(t, "v9.9.9", tags[3].Name)
+		assert.Error(t, tags[3].Err)
+
+		var visited []Commit
+		err = history.Walk(context.Background(), hash, func(commit Commit) error {
+			visited = append(visited, commit)
+			if commit.Hash == hashes[1].String() {
+				return ErrStopWalk
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, visited, 2)
+		assert.Equal(t, hashes[2].String(), visited[0].Hash)
+		assert.Equal(t, []string{hashes[1].String()}, visited[0].Parents)
+		assert.Equal(t, hashes[1].String(), visited[1].Hash)
+
+		visited = nil
+		err = history.Walk(context.Background(), hash, func(commit Commit) error {
+			visited = append(visited, commit)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, visited, 3)
+		assert.Empty(t, visited[2].Parents)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = history.Tags(ctx, func(string) bool { return true })
+		require.ErrorIs(t, err, context.Canceled)
+		err = history.Walk(ctx, hash, func(Commit) error { return nil })
+		require.ErrorIs(t, err, context.Canceled)
+
+		shallow, err := history.Shallow()
+		require.NoError(t, err)
+		assert.Empty(t, shallow)
+		require.NoError(t, repo.Storer.SetShallow([]plumbing.Hash{hashes[1]}))
+		shallow, err = history.Shallow()
+		require.NoError(t, err)
+		assert.Equal(t, []string{hashes[1].String()}, shallow)
+	})
+}
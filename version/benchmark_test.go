@@ -0,0 +1,171 @@
+package version
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/stretchr/testify/require"
+)
+
+// newSyntheticRepo creates a repository with a linear history of the given number of commits,
+// in which every step-th commit is tagged. Every other tag is annotated. The objects are written
+// to the storer directly, which is much faster than committing with the worktree. If packed is
+// set, the objects and refs are packed, like in a repository after git gc.
+func newSyntheticRepo(tb testing.TB, commits, step int, packed bool) string {
+	tb.Helper()
+	dir := tb.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(tb, err)
+
+	tree := repo.Storer.NewEncodedObject()
+	require.NoError(tb, (&object.Tree{}).Encode(tree))
+	treeHash, err := repo.Storer.SetEncodedObject(tree)
+	require.NoError(tb, err)
+
+	signature := object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Unix(1715601600, 0)}
+	var parent plumbing.Hash
+	for index := range commits {
+		signature.When = signature.When.Add(time.Minute)
+		commit := object.Commit{
+			Author:    signature,
+			Committer: signature,
+			Message:   fmt.Sprintf("commit %d", index),
+			TreeHash:  treeHash,
+		}
+		if !parent.IsZero() {
+			commit.ParentHashes = []plumbing.Hash{parent}
+		}
+		obj := repo.Storer.NewEncodedObject()
+		require.NoError(tb, commit.Encode(obj))
+		parent, err = repo.Storer.SetEncodedObject(obj)
+		require.NoError(tb, err)
+		if index%step != 0 {
+			continue
+		}
+		name := fmt.Sprintf("v%d.%d.%d", index/10000, index/100%100, index%100)
+		var opts *git.CreateTagOptions
+		if index/step%2 == 1 {
+			opts = &git.CreateTagOptions{Tagger: &signature, Message: name}
+		}
+		_, err = repo.CreateTag(name, parent, opts)
+		require.NoError(tb, err)
+	}
+	require.NoError(tb, repo.Storer.SetReference(plumbing.NewHashReference("refs/heads/master", parent)))
+
+	if packed {
+		storage, ok := repo.Storer.(*filesystem.Storage)
+		require.True(tb, ok)
+		require.NoError(tb, storage.PackRefs())
+		if _, err = exec.LookPath("git"); err == nil {
+			cmd := exec.Command("git", "repack", "-a", "-d", "-q")
+			cmd.Dir = dir
+			require.NoError(tb, cmd.Run())
+		}
+	}
+	return dir
+}
+
+func BenchmarkGitDescribe(b *testing.B) {
+	for _, size := range []struct {
+		commits, step int
+	}{
+		{1000, 100},
+		{10000, 1},
+	} {
+		for _, packed := range []bool{false, true} {
+			layout := "loose"
+			if packed {
+				layout = "packed"
+			}
+			b.Run(fmt.Sprintf("commits=%d/tags=%d/%s", size.commits, size.commits/size.step, layout), func(b *testing.B) {
+				dir := newSyntheticRepo(b, size.commits, size.step, packed)
+				for _, backend := range []struct {
+					name string
+					open OpenBackend
+				}{
+					{"go-git", OpenGoGit},
+					{"git", OpenGitCLI},
+				} {
+					b.Run(backend.name, func(b *testing.B) {
+						if backend.name == "git" {
+							if _, err := exec.LookPath("git"); err != nil {
+								b.Skip("git binary not found")
+							}
+						}
+						for range b.N {
+							_, err := GitDescribe(dir, WithBackend(backend.open), WithMatchPattern("*"))
+							require.NoError(b, err)
+						}
+					})
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkCommitGraph compares walks with and without a commit-graph file. The whole history is
+// walked to the tag of the root commit, or only until the tag of a branch that forks off the
+// 9000th commit becomes unreachable.
+func BenchmarkCommitGraph(b *testing.B) {
+	for _, graph := range []bool{false, true} {
+		b.Run(fmt.Sprintf("commit-graph=%t", graph), func(b *testing.B) {
+			dir := newSyntheticRepo(b, 10000, 1000, true)
+			repo, err := git.PlainOpen(dir)
+			require.NoError(b, err)
+			fork, err := repo.ResolveRevision("v0.90.0^{commit}")
+			require.NoError(b, err)
+			signature := object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Unix(1716201600, 0)}
+			obj := repo.Storer.NewEncodedObject()
+			parent, err := repo.CommitObject(*fork)
+			require.NoError(b, err)
+			require.NoError(b, (&object.Commit{
+				Author:       signature,
+				Committer:    signature,
+				Message:      "release",
+				TreeHash:     parent.TreeHash,
+				ParentHashes: []plumbing.Hash{*fork},
+			}).Encode(obj))
+			release, err := repo.Storer.SetEncodedObject(obj)
+			require.NoError(b, err)
+			_, err = repo.CreateTag("v1.0.0", release, nil)
+			require.NoError(b, err)
+			if graph {
+				writeCommitGraph(b, dir)
+			}
+			for _, scenario := range []struct {
+				name string
+				opts []Option
+				err  error
+			}{
+				{"root-tag", []Option{WithMatchPattern("v0.0.0")}, nil},
+				{"forked-tag", []Option{WithMatchPattern("v1.*"), WithRequireTag()}, ErrNoMatchingTag},
+			} {
+				b.Run(scenario.name, func(b *testing.B) {
+					for range b.N {
+						_, err := GitDescribe(dir, scenario.opts...)
+						require.ErrorIs(b, err, scenario.err)
+					}
+				})
+			}
+		})
+	}
+}
+
+func BenchmarkTags(b *testing.B) {
+	dir := newSyntheticRepo(b, 10000, 1, true)
+	backend, err := OpenGoGit(dir)
+	require.NoError(b, err)
+	b.ResetTimer()
+	for range b.N {
+		tags, err := backend.Tags(context.Background(), func(string) bool { return true })
+		require.NoError(b, err)
+		require.Len(b, tags, 10000)
+	}
+}
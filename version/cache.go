@@ -0,0 +1,244 @@
+package version
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cacheFormat is increased whenever the format of the cache file changes.
+const cacheFormat = 1
+
+// cacheFile is the name of the cache file in the git directory.
+const cacheFile = "git-semver-cache.json"
+
+// maxCacheEntries limits the size of the cache file. If it is exceeded, the cache starts over.
+const maxCacheEntries = 64
+
+// GitDirBackend is implemented by backends that store the repository on disk. The cache of
+// [WithCache] is only used with such backends.
+type GitDirBackend interface {
+	// GitDir returns the absolute path of the git directory of the repository.
+	GitDir() (string, error)
+}
+
+// WithCache stores the result of [GitDescribe] in a file in the git directory of the repository
+// and reuses it for the same commit. The cache is invalidated as soon as a tag is added, moved or
+// deleted, the shallow boundary changes, or different options are used. The branch and the dirty
+// state are always read from the repository. Options with custom parsers, that can't be compared
+// between runs, disable the cache. Failures to read or write the cache are only logged.
+func WithCache() Option {
+	return func(opts *options) {
+		opts.cache = true
+	}
+}
+
+// record adds a setting that influences the result of [GitDescribe] to the cache key.
+func (o *options) record(name, value string) {
+	o.cacheKeys = append(o.cacheKeys, name+"="+strconv.Quote(value))
+}
+
+// parserKey returns the cache key of the parsers of this package. Other parsers can't be
+// identified between runs.
+func parserKey(parser Parser) (string, bool) {
+	switch parser := parser.(type) {
+	case prefixParser:
+		return fmt.Sprintf("prefix:%s:%t", parser.prefix, parser.lenient), true
+	case *RegexpParser:
+		return "regexp:" + parser.expr.String(), true
+	default:
+		return "", false
+	}
+}
+
+type cacheEntry struct {
+	LastTag         string `json:"last_tag,omitempty"`
+	HighestTag      string `json:"highest_tag,omitempty"`
+	CommitsSinceTag int    `json:"commits_since_tag"`
+	Annotated       bool   `json:"annotated,omitempty"`
+	Signed          bool   `json:"signed,omitempty"`
+	InitialVersion  string `json:"initial_version,omitempty"`
+	Shallow         bool   `json:"shallow,omitempty"`
+}
+
+type cacheContent struct {
+	Format  int                   `json:"format"`
+	Refs    string                `json:"refs"`
+	Entries map[string]cacheEntry `json:"entries"`
+}
+
+// describeCache holds the cache file of a repository together with the key of the described
+// commit. A nil cache is valid and never contains an entry.
+type describeCache struct {
+	path    string
+	key     string
+	content cacheContent
+	logger  Logger
+}
+
+// openCache reads the cache of the repository if it is enabled. Entries that were stored for a
+// different state of the tags are dropped.
+func openCache(backend Backend, options *options, hash string) *describeCache {
+	if !options.cache {
+		return nil
+	}
+	if options.uncacheable {
+		options.logger.Printf("Not using the cache, because a custom tag parser is used")
+		return nil
+	}
+	gitDirBackend, ok := backend.(GitDirBackend)
+	if !ok {
+		options.logger.Printf("Not using the cache, because the repository isn't stored on disk")
+		return nil
+	}
+	gitDir, err := gitDirBackend.GitDir()
+	if err != nil {
+		options.logger.Printf("Not using the cache: %s", err)
+		return nil
+	}
+	commonDir := commonGitDir(gitDir)
+	refs, err := refsDigest(commonDir)
+	if err != nil {
+		options.logger.Printf("Not using the cache: %s", err)
+		return nil
+	}
+	key := sha256.Sum256([]byte(hash + "\x00" + strings.Join(options.cacheKeys, "\x00")))
+	cache := describeCache{
+		path:   filepath.Join(commonDir, cacheFile),
+		key:    hex.EncodeToString(key[:]),
+		logger: options.logger,
+	}
+	content, err := os.ReadFile(cache.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		options.logger.Printf("Ignoring the cache: %s", err)
+	} else if err == nil {
+		if err = json.Unmarshal(content, &cache.content); err != nil {
+			options.logger.Printf("Ignoring the cache: %s", err)
+		}
+	}
+	if cache.content.Format != cacheFormat || cache.content.Refs != refs || cache.content.Entries == nil {
+		cache.content = cacheContent{Format: cacheFormat, Refs: refs, Entries: map[string]cacheEntry{}}
+	}
+	return &cache
+}
+
+// load copies the cached result into ref and reports whether there was one.
+func (c *describeCache) load(ref *RepoHead) bool {
+	if c == nil {
+		return false
+	}
+	entry, found := c.content.Entries[c.key]
+	if !found {
+		return false
+	}
+	ref.LastTag = entry.LastTag
+	ref.HighestTag = entry.HighestTag
+	ref.CommitsSinceTag = entry.CommitsSinceTag
+	ref.Annotated = entry.Annotated
+	ref.Signed = entry.Signed
+	ref.InitialVersion = entry.InitialVersion
+	ref.Shallow = entry.Shallow
+	return true
+}
+
+// store adds the result to the cache file. The file is replaced atomically, so that concurrent
+// runs never read a partial file.
+func (c *describeCache) store(ref *RepoHead) {
+	if c == nil {
+		return
+	}
+	if len(c.content.Entries) >= maxCacheEntries {
+		c.content.Entries = map[string]cacheEntry{}
+	}
+	c.content.Entries[c.key] = cacheEntry{
+		LastTag:         ref.LastTag,
+		HighestTag:      ref.HighestTag,
+		CommitsSinceTag: ref.CommitsSinceTag,
+		Annotated:       ref.Annotated,
+		Signed:          ref.Signed,
+		InitialVersion:  ref.InitialVersion,
+		Shallow:         ref.Shallow,
+	}
+	if err := c.write(); err != nil {
+		c.logger.Printf("Failed to write the cache: %s", err)
+	}
+}
+
+func (c *describeCache) write() error {
+	content, err := json.Marshal(c.content)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(c.path), cacheFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name()) // nolint: errcheck
+	if _, err = file.Write(content); err != nil {
+		file.Close() // nolint: errcheck,gosec
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), c.path)
+}
+
+// commonGitDir returns the directory that is shared by all worktrees of a repository, which
+// contains the refs. For the main worktree it is the git directory itself.
+func commonGitDir(gitDir string) string {
+	content, err := os.ReadFile(filepath.Join(gitDir, "commondir")) // nolint: gosec
+	if err != nil {
+		return gitDir
+	}
+	commonDir := strings.TrimSpace(string(content))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return filepath.Clean(commonDir)
+}
+
+// refsDigest hashes the files that change whenever a tag or the shallow boundary changes, i.e.
+// packed-refs, the loose refs in refs/tags and shallow.
+func refsDigest(commonDir string) (string, error) {
+	digest := sha256.New()
+	add := func(name string) error {
+		content, err := os.ReadFile(filepath.Join(commonDir, name)) // nolint: gosec
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		fmt.Fprintf(digest, "%s\x00%d\x00", filepath.ToSlash(name), len(content))
+		digest.Write(content)
+		return nil
+	}
+	for _, name := range []string{"packed-refs", "shallow"} {
+		if err := add(name); err != nil {
+			return "", err
+		}
+	}
+	err := filepath.WalkDir(filepath.Join(commonDir, "refs", "tags"), func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil || entry.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(commonDir, path)
+		if err != nil {
+			return err
+		}
+		return add(name)
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
@@ -0,0 +1,165 @@
+package version
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// poisonCache sets the distance of all cached entries to 99, so that a cache hit can be told
+// apart from a fresh result.
+func poisonCache(t *testing.T, dir string) {
+	t.Helper()
+	path := filepath.Join(dir, ".git", cacheFile)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var cache cacheContent
+	require.NoError(t, json.Unmarshal(content, &cache))
+	require.NotEmpty(t, cache.Entries)
+	for key, entry := range cache.Entries {
+		entry.CommitsSinceTag = 99
+		cache.Entries[key] = entry
+	}
+	content, err = json.Marshal(cache)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+}
+
+func TestGitDescribeCache(t *testing.T) {
+	testBackends(t, func(t *testing.T, backend Option) {
+		dir := t.TempDir()
+		repo, err := git.PlainInit(dir, false)
+		require.NoError(t, err)
+		worktree, err := repo.Worktree()
+		require.NoError(t, err)
+
+		signature := object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+		commit := func() plumbing.Hash {
+			signature.When = signature.When.Add(time.Minute)
+			hash, err := worktree.Commit("commit", &git.CommitOptions{
+				Author:            &signature,
+				Committer:         &signature,
+				AllowEmptyCommits: true,
+			})
+			require.NoError(t, err)
+			return hash
+		}
+		first := commit()
+		_, err = repo.CreateTag("v1.0.0", first, nil)
+		require.NoError(t, err)
+		second := commit()
+		commit()
+
+		describe := func(opts ...Option) *RepoHead {
+			t.Helper()
+			head, err := GitDescribe(dir, append(opts, backend, WithCache())...)
+			require.NoError(t, err)
+			return head
+		}
+
+		head := describe()
+		assert.Equal(t, "v1.0.0", head.LastTag)
+		assert.Equal(t, 2, head.CommitsSinceTag)
+		assert.Equal(t, "master", head.Branch)
+		assert.FileExists(t, filepath.Join(dir, ".git", cacheFile))
+
+		poisonCache(t, dir)
+		head = describe()
+		assert.Equal(t, 99, head.CommitsSinceTag, "cached result is used")
+		assert.Equal(t, "master", head.Branch)
+
+		head, err = GitDescribe(dir, backend)
+		require.NoError(t, err)
+		assert.Equal(t, 2, head.CommitsSinceTag, "cache is disabled by default")
+
+		t.Run("different options", func(t *testing.T) {
+			poisonCache(t, dir)
+			assert.Equal(t, 2, describe(WithMatchPattern("v*")).CommitsSinceTag)
+			assert.Equal(t, 2, describe(WithSelection(SelectHighest)).CommitsSinceTag)
+			assert.Equal(t, 2, describe(WithParser(LenientParser("v"))).CommitsSinceTag)
+		})
+
+		t.Run("custom parser", func(t *testing.T) {
+			poisonCache(t, dir)
+			assert.Equal(t, 2, describe(WithParser(struct{ Parser }{PrefixParser("v")})).CommitsSinceTag)
+		})
+
+		t.Run("new tag", func(t *testing.T) {
+			poisonCache(t, dir)
+			_, err = repo.CreateTag("v1.1.0", second, nil)
+			require.NoError(t, err)
+			head := describe()
+			assert.Equal(t, "v1.1.0", head.LastTag)
+			assert.Equal(t, 1, head.CommitsSinceTag)
+		})
+
+		t.Run("deleted tag", func(t *testing.T) {
+			poisonCache(t, dir)
+			require.NoError(t, repo.DeleteTag("v1.1.0"))
+			head := describe()
+			assert.Equal(t, "v1.0.0", head.LastTag)
+			assert.Equal(t, 2, head.CommitsSinceTag)
+		})
+
+		t.Run("packed tags", func(t *testing.T) {
+			poisonCache(t, dir)
+			packed := "# pack-refs with: peeled fully-peeled sorted \n" + second.String() + " refs/tags/v1.2.0\n"
+			require.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "packed-refs"), []byte(packed), 0o600))
+			head := describe()
+			assert.Equal(t, "v1.2.0", head.LastTag)
+			assert.Equal(t, 1, head.CommitsSinceTag)
+			require.NoError(t, os.Remove(filepath.Join(dir, ".git", "packed-refs")))
+		})
+
+		t.Run("new commit", func(t *testing.T) {
+			describe()
+			poisonCache(t, dir)
+			commit()
+			head := describe()
+			assert.Equal(t, "v1.0.0", head.LastTag)
+			assert.Equal(t, 3, head.CommitsSinceTag)
+		})
+
+		t.Run("shallow boundary", func(t *testing.T) {
+			poisonCache(t, dir)
+			require.NoError(t, repo.Storer.SetShallow([]plumbing.Hash{second}))
+			head := describe(WithShallowFallback())
+			assert.True(t, head.Shallow)
+			poisonCache(t, dir)
+			require.NoError(t, repo.Storer.SetShallow(nil))
+			head = describe(WithShallowFallback())
+			assert.False(t, head.Shallow)
+			assert.Equal(t, 3, head.CommitsSinceTag)
+		})
+	})
+}
+
+func TestGitDescribeCacheInMemory(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	require.NoError(t, err)
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	signature := object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+	_, err = worktree.Commit("commit", &git.CommitOptions{
+		Author:            &signature,
+		Committer:         &signature,
+		AllowEmptyCommits: true,
+	})
+	require.NoError(t, err)
+
+	var logger testLogger
+	head, err := GitDescribeRepository(repo, WithCache(), WithLogger(&logger))
+	require.NoError(t, err)
+	assert.Equal(t, 1, head.CommitsSinceTag)
+	assert.Equal(t, []string{"Not using the cache: the repository isn't stored on disk"}, logger.messages)
+}
@@ -0,0 +1,78 @@
+package version
+
+import (
+	"errors"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// DefaultChannel is the pre-release label that is used for untagged commits if no channel was
+// selected.
+const DefaultChannel = "dev"
+
+// ChannelRule assigns the pre-release label Label to all branches matching the glob Pattern.
+// The pattern allows the syntax described for path.Match.
+type ChannelRule struct {
+	Pattern string
+	Label   string
+}
+
+// ChannelRules is an ordered list of [ChannelRule]s. It can be used as flag.Value, where each
+// invocation of Set appends a rule of the form pattern=label.
+type ChannelRules []ChannelRule
+
+func (r *ChannelRules) String() string {
+	rules := make([]string, 0, len(*r))
+	for _, rule := range *r {
+		rules = append(rules, rule.Pattern+"="+rule.Label)
+	}
+	return strings.Join(rules, ",")
+}
+
+func (r *ChannelRules) Set(value string) error {
+	pattern, label, found := strings.Cut(value, "=")
+	if !found || pattern == "" {
+		return errors.New(`parse error`)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+	*r = append(*r, ChannelRule{Pattern: pattern, Label: label})
+	return nil
+}
+
+// Match returns the label of the first rule whose pattern matches the branch.
+func (r ChannelRules) Match(branch string) (string, bool) {
+	for _, rule := range r {
+		if matched, _ := path.Match(rule.Pattern, branch); matched {
+			return rule.Label, true
+		}
+	}
+	return "", false
+}
+
+var invalidIdentifierChars = regexp.MustCompile(`[^0-9A-Za-z.-]+`)
+
+// SanitizeIdentifier turns an arbitrary string like a branch name into a valid SemVer
+// pre-release identifier. Invalid characters are replaced with a hyphen, empty identifiers are
+// dropped and leading zeros are removed from numeric identifiers. E.g. feature/foo_bar becomes
+// feature-foo-bar.
+func SanitizeIdentifier(s string) string {
+	s = invalidIdentifierChars.ReplaceAllString(s, "-")
+	parts := strings.Split(s, ".")
+	result := parts[:0]
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		if strings.Trim(part, "0123456789") == "" {
+			part = strings.TrimLeft(part, "0")
+			if part == "" {
+				part = "0"
+			}
+		}
+		result = append(result, part)
+	}
+	return strings.Join(result, ".")
+}
@@ -0,0 +1,69 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelRules(t *testing.T) {
+	var rules ChannelRules
+	require.NoError(t, rules.Set("main=dev"))
+	require.NoError(t, rules.Set("release/*=rc"))
+	require.NoError(t, rules.Set("feature/*={{.Branch}}"))
+	assert.Equal(t, "main=dev,release/*=rc,feature/*={{.Branch}}", rules.String())
+
+	for _, test := range []struct {
+		branch string
+		label  string
+		found  bool
+	}{
+		{"main", "dev", true},
+		{"release/1.4", "rc", true},
+		{"feature/foo", "{{.Branch}}", true},
+		{"feature/foo/bar", "", false},
+		{"develop", "", false},
+	} {
+		label, found := rules.Match(test.branch)
+		assert.Equal(t, test.found, found, test.branch)
+		assert.Equal(t, test.label, label, test.branch)
+	}
+}
+
+func TestParseChannelRuleInvalid(t *testing.T) {
+	var rules ChannelRules
+	require.EqualError(t, rules.Set("main"), "parse error")
+	require.EqualError(t, rules.Set("=dev"), "parse error")
+	require.Error(t, rules.Set("[=dev"))
+	assert.Empty(t, rules)
+}
+
+func TestSanitizeIdentifier(t *testing.T) {
+	for _, test := range []struct {
+		in  string
+		out string
+	}{
+		{"dev", "dev"},
+		{"feature/foo_bar", "feature-foo-bar"},
+		{"feature/JIRA-123", "feature-JIRA-123"},
+		{"fix/ümlaut", "fix-mlaut"},
+		{"release..1.04", "release.1.4"},
+		{"hotfix/00", "hotfix-00"},
+		{"a.007", "a.7"},
+		{"..", ""},
+	} {
+		assert.Equal(t, test.out, SanitizeIdentifier(test.in), test.in)
+	}
+}
+
+func TestPreReleaseChannel(t *testing.T) {
+	ver := Version{Major: 1, Minor: 2, Patch: 4, Commits: 3, Channel: "feature-foo"}
+	assert.Equal(t, "feature-foo.3", ver.PreRelease())
+
+	ver.preRelease = "rc.1"
+	assert.Equal(t, "rc.1.feature-foo.3", ver.PreRelease())
+
+	ver.Commits = 0
+	assert.Equal(t, "rc.1", ver.PreRelease())
+}
@@ -0,0 +1,113 @@
+package version
+
+import (
+	"errors"
+	"math"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	commitgraphfmt "github.com/go-git/go-git/v5/plumbing/format/commitgraph/v2"
+	"github.com/go-git/go-git/v5/plumbing/object/commitgraph"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// newGoGitBackend creates the go-git backend for repo. If the repository contains a commit-graph
+// file, as written by git gc or git commit-graph write, the history is read from it instead of
+// decoding every commit object. Commits that were created after the file was written are read
+// from the object storage.
+func newGoGitBackend(repo *git.Repository) goGitBackend {
+	backend := goGitBackend{repo: repo, nodes: commitgraph.NewObjectCommitNodeIndex(repo.Storer)}
+	storage, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return backend
+	}
+	index, err := commitgraphfmt.OpenChainOrFileIndex(storage.Filesystem())
+	if err != nil {
+		return backend
+	}
+	backend.graph = index
+	backend.nodes = commitgraph.NewGraphCommitNodeIndex(index, repo.Storer)
+	return backend
+}
+
+// generation returns the generation number of a commit from the commit-graph. Commits outside
+// of the graph have the highest possible generation, because they can't be reached from commits
+// in the graph. Zero is returned if the generation is unknown.
+func (b goGitBackend) generation(hash string) (uint64, error) {
+	if b.graph == nil {
+		return 0, nil
+	}
+	index, err := b.graph.GetIndexByHash(plumbing.NewHash(hash))
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return math.MaxUint64, nil
+	} else if err != nil {
+		return 0, err
+	}
+	data, err := b.graph.GetCommitDataByIndex(index)
+	if err != nil {
+		return 0, err
+	}
+	return data.Generation, nil
+}
+
+// generationIndex is implemented by backends that know the generation numbers of commits.
+type generationIndex interface {
+	generation(hash string) (uint64, error)
+}
+
+// reachability tracks the commits that the walk still has to visit, so that it can be ended as
+// soon as none of the tagged commits can be reached anymore. The generation of a commit is
+// greater than the generations of all its ancestors, so a tagged commit can't be reached from
+// commits of a lower generation.
+type reachability struct {
+	index   generationIndex
+	lowest  uint64
+	pending map[string]uint64
+	visited map[string]bool
+}
+
+// newReachability returns nil if the backend doesn't know the generation numbers of all tagged
+// commits, in which case the whole history has to be walked.
+func newReachability(backend Backend, tags map[string]Tag) *reachability {
+	index, ok := backend.(generationIndex)
+	if !ok || len(tags) == 0 {
+		return nil
+	}
+	lowest := uint64(math.MaxUint64)
+	for hash := range tags {
+		generation, err := index.generation(hash)
+		if err != nil || generation == 0 {
+			return nil
+		}
+		lowest = min(lowest, generation)
+	}
+	return &reachability{
+		index:   index,
+		lowest:  lowest,
+		pending: map[string]uint64{},
+		visited: map[string]bool{},
+	}
+}
+
+// visit records that commit was visited and reports whether a tagged commit can still be
+// reached from the commits that haven't been visited yet.
+func (r *reachability) visit(commit Commit) bool {
+	delete(r.pending, commit.Hash)
+	r.visited[commit.Hash] = true
+	for _, parent := range commit.Parents {
+		if _, found := r.pending[parent]; found || r.visited[parent] {
+			continue
+		}
+		generation, err := r.index.generation(parent)
+		if err != nil || generation == 0 {
+			generation = math.MaxUint64
+		}
+		r.pending[parent] = generation
+	}
+	for _, generation := range r.pending {
+		if generation >= r.lowest {
+			return true
+		}
+	}
+	return false
+}
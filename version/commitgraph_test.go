@@ -0,0 +1,198 @@
+package version
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCommitGraph writes the commit-graph file of the repository with git.
+func writeCommitGraph(tb testing.TB, dir string) {
+	tb.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		tb.Skip("git binary not found")
+	}
+	cmd := exec.Command("git", "commit-graph", "write", "--reachable")
+	cmd.Dir = dir
+	require.NoError(tb, cmd.Run())
+}
+
+// countingBackend counts the commits that are visited by walks.
+type countingBackend struct {
+	goGitBackend
+	visited int
+}
+
+func (b *countingBackend) Walk(ctx context.Context, hash string, visit func(Commit) error) error {
+	return b.goGitBackend.Walk(ctx, hash, func(commit Commit) error {
+		b.visited++
+		return visit(commit)
+	})
+}
+
+// newForkedRepo creates a repository with ten commits on master, of which the sixth is tagged
+// v2.0.0, and a release branch that forks off the fourth commit with the tag v1.1.0.
+func newForkedRepo(t *testing.T) (*git.Repository, string) {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	tree := repo.Storer.NewEncodedObject()
+	require.NoError(t, (&object.Tree{}).Encode(tree))
+	treeHash, err := repo.Storer.SetEncodedObject(tree)
+	require.NoError(t, err)
+
+	signature := object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Unix(1715601600, 0)}
+	commit := func(parents ...plumbing.Hash) plumbing.Hash {
+		signature.When = signature.When.Add(time.Minute)
+		obj := repo.Storer.NewEncodedObject()
+		require.NoError(t, (&object.Commit{
+			Author:       signature,
+			Committer:    signature,
+			Message:      "commit",
+			TreeHash:     treeHash,
+			ParentHashes: parents,
+		}).Encode(obj))
+		hash, err := repo.Storer.SetEncodedObject(obj)
+		require.NoError(t, err)
+		return hash
+	}
+	var hashes []plumbing.Hash
+	for index := range 10 {
+		if index == 0 {
+			hashes = append(hashes, commit())
+		} else {
+			hashes = append(hashes, commit(hashes[index-1]))
+		}
+	}
+	release := commit(commit(hashes[3]))
+	_, err = repo.CreateTag("v1.1.0", release, nil)
+	require.NoError(t, err)
+	_, err = repo.CreateTag("v2.0.0", hashes[5], nil)
+	require.NoError(t, err)
+	require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference("refs/heads/master", hashes[9])))
+	return repo, dir
+}
+
+func TestWalkCommitGraph(t *testing.T) {
+	dir := newSyntheticRepo(t, 50, 5, false)
+	walk := func() ([]Commit, []TagRef) {
+		t.Helper()
+		backend, err := OpenGoGit(dir)
+		require.NoError(t, err)
+		defer closeBackend(backend)
+		hash, _, err := backend.Head()
+		require.NoError(t, err)
+		var commits []Commit
+		require.NoError(t, backend.Walk(context.Background(), hash, func(commit Commit) error {
+			commit.When = commit.When.UTC()
+			commits = append(commits, commit)
+			return nil
+		}))
+		tags, err := backend.Tags(context.Background(), func(string) bool { return true })
+		require.NoError(t, err)
+		for index := range tags {
+			tags[index].When = tags[index].When.UTC()
+		}
+		return commits, tags
+	}
+	commits, tags := walk()
+	require.Len(t, commits, 50)
+	require.Len(t, tags, 10)
+
+	writeCommitGraph(t, dir)
+	backend, err := OpenGoGit(dir)
+	require.NoError(t, err)
+	assert.NotNil(t, backend.(goGitBackend).graph)     // nolint: forcetypeassert
+	require.NoError(t, backend.(goGitBackend).Close()) // nolint: forcetypeassert
+	graphCommits, graphTags := walk()
+	assert.Equal(t, commits, graphCommits)
+	assert.Equal(t, tags, graphTags)
+}
+
+func TestGitDescribeRequireTag(t *testing.T) {
+	repo, dir := newForkedRepo(t)
+	describe := func(opts ...Option) (*RepoHead, int, error) {
+		t.Helper()
+		options, err := newOptions(opts)
+		require.NoError(t, err)
+		opened, err := OpenGoGit(dir)
+		require.NoError(t, err)
+		backend := countingBackend{goGitBackend: opened.(goGitBackend)} // nolint: forcetypeassert
+		defer backend.Close()                                           // nolint: errcheck
+		head, err := describe(context.Background(), &backend, options)
+		return head, backend.visited, err
+	}
+
+	head, visited, err := describe(WithRequireTag())
+	require.NoError(t, err)
+	assert.Equal(t, "v2.0.0", head.LastTag)
+	assert.Equal(t, 4, head.CommitsSinceTag)
+	assert.Equal(t, 5, visited)
+
+	_, visited, err = describe(WithRequireTag(), WithMatchPattern("v1.*"))
+	require.ErrorIs(t, err, ErrNoMatchingTag)
+	assert.Equal(t, 10, visited, "without a commit-graph the whole history is walked")
+
+	_, visited, err = describe(WithRequireTag(), WithMatchPattern("v3.*"))
+	require.ErrorIs(t, err, ErrNoMatchingTag)
+	assert.Zero(t, visited)
+
+	head, _, err = describe(WithMatchPattern("v1.*"))
+	require.NoError(t, err)
+	assert.Empty(t, head.LastTag)
+	assert.Equal(t, 10, head.CommitsSinceTag)
+
+	writeCommitGraph(t, dir)
+	head, visited, err = describe(WithRequireTag())
+	require.NoError(t, err)
+	assert.Equal(t, "v2.0.0", head.LastTag)
+	assert.Equal(t, 4, head.CommitsSinceTag)
+	assert.Equal(t, 5, visited)
+
+	_, visited, err = describe(WithRequireTag(), WithMatchPattern("v1.*"))
+	require.ErrorIs(t, err, ErrNoMatchingTag)
+	assert.Equal(t, 5, visited, "the walk ends below the generation of v1.1.0")
+
+	t.Run("commit after the commit-graph", func(t *testing.T) {
+		worktree, err := repo.Worktree()
+		require.NoError(t, err)
+		signature := object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Unix(1715611600, 0)}
+		_, err = worktree.Commit("commit", &git.CommitOptions{
+			Author:            &signature,
+			Committer:         &signature,
+			AllowEmptyCommits: true,
+		})
+		require.NoError(t, err)
+
+		_, visited, err = describe(WithRequireTag(), WithMatchPattern("v1.*"))
+		require.ErrorIs(t, err, ErrNoMatchingTag)
+		assert.Equal(t, 6, visited)
+
+		head, visited, err = describe(WithRequireTag())
+		require.NoError(t, err)
+		assert.Equal(t, "v2.0.0", head.LastTag)
+		assert.Equal(t, 5, head.CommitsSinceTag)
+		assert.Equal(t, 6, visited)
+	})
+}
+
+func TestGitDescribeRequireTagBackends(t *testing.T) {
+	testBackends(t, func(t *testing.T, backend Option) {
+		_, dir := newForkedRepo(t)
+		head, err := GitDescribe(dir, backend, WithRequireTag(), WithMatchPattern("v2.*"))
+		require.NoError(t, err)
+		assert.Equal(t, "v2.0.0", head.LastTag)
+
+		head, err = GitDescribe(dir, backend, WithRequireTag(), WithMatchPattern("v1.*"))
+		assert.Nil(t, head)
+		require.ErrorIs(t, err, ErrNoMatchingTag)
+	})
+}
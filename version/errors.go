@@ -0,0 +1,51 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors that classify the failures of this package. They can be tested for with
+// errors.Is, while the returned errors also wrap the underlying go-git errors.
+var (
+	// ErrNotRepository is returned if the path doesn't point into a git repository.
+	ErrNotRepository = errors.New("failed to open repo")
+	// ErrNoHead is returned if the head of the repository can't be resolved, e.g. because
+	// there are no commits yet.
+	ErrNoHead = errors.New("failed to retrieve repo head")
+	// ErrUnknownRevision is returned if the revision given with [WithRevision] can't be resolved
+	// to a commit.
+	ErrUnknownRevision = errors.New("unknown revision")
+	// ErrNoMatchingTag is returned if a tag was required, but none was found.
+	ErrNoMatchingTag = errors.New("no matching tag")
+	// ErrShallowClone is returned if the history of a shallow clone ends before a tag is found.
+	ErrShallowClone = errors.New("shallow clone")
+	// ErrInvalidTag is returned if a tag can't be parsed as version.
+	ErrInvalidTag = errors.New("invalid version tag")
+	// ErrInvalidPattern is returned if a tag match pattern is malformed.
+	ErrInvalidPattern = errors.New("invalid match pattern")
+	// ErrInvalidParser is returned if the configuration of a tag parser is invalid.
+	ErrInvalidParser = errors.New("invalid tag parser")
+	// ErrInvalidOption is returned if an option is given an invalid value, e.g. an initial version
+	// that isn't a version.
+	ErrInvalidOption = errors.New("invalid option")
+	// ErrInvalidReleaseLine is returned if a release line is malformed, can't be derived from the
+	// branch or doesn't permit the bump target.
+	ErrInvalidReleaseLine = errors.New("invalid release line")
+	// ErrInvalidFormat is returned if a format string is invalid.
+	ErrInvalidFormat = errors.New("invalid format")
+)
+
+// DepthError is returned if no matching tag is found within the number of commits given with
+// [WithMaxDepth]. It wraps [ErrNoMatchingTag].
+type DepthError struct {
+	Depth int
+}
+
+func (e *DepthError) Error() string {
+	return fmt.Sprintf("no tag within %d commits", e.Depth)
+}
+
+func (e *DepthError) Unwrap() error {
+	return ErrNoMatchingTag
+}
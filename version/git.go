@@ -1,153 +1,437 @@
 package version
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"path/filepath"
 	"time"
 
 	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing"
-	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/go-git/go-git/v5/plumbing/storer"
-	"golang.org/x/mod/semver"
 )
 
 // RepoHead provides statistics about the head commit of a git
 // repository like its commit-hash, the number of commits since
-// the last tag and the name of the last tag.
+// the last tag and the name of the last tag. Branch is empty if
+// the head is detached. HighestTag is the name of the highest
+// matching tag in the whole repository, regardless of whether
+// it is reachable from the head commit. Annotated and Signed
+// describe the last tag. InitialVersion is only set if no tag
+// matched and an initial version was configured with
+// [WithInitialVersion]. Shallow is set if the history of a
+// shallow clone ended before a tag was found, see
+// [WithShallowFallback].
 type RepoHead struct {
 	LastTag         string
+	HighestTag      string
 	CommitsSinceTag int
 	Hash            string
+	Branch          string
+	Dirty           bool
+	Annotated       bool
+	Signed          bool
+	InitialVersion  string
+	Shallow         bool
 }
 
+// ExactMatch returns an error unless the head commit itself is tagged, similar to
+// git describe --exact-match. The returned error wraps [ErrNoMatchingTag]. If annotated
+// or signed is set, the tag must furthermore be an annotated or a signed tag respectively.
+// The signature is not verified.
+func (h *RepoHead) ExactMatch(annotated, signed bool) error {
+	switch {
+	case h.LastTag == "" || h.CommitsSinceTag > 0:
+		return fmt.Errorf("%w: head commit %s is not tagged", ErrNoMatchingTag, h.Hash)
+	case (annotated || signed) && !h.Annotated:
+		return fmt.Errorf("%w: tag %s is not annotated", ErrNoMatchingTag, h.LastTag)
+	case signed && !h.Signed:
+		return fmt.Errorf("%w: tag %s is not signed", ErrNoMatchingTag, h.LastTag)
+	}
+	return nil
+}
+
+// Logger receives diagnostic messages, e.g. about tags that are skipped because they
+// can't be resolved. It is satisfied by [log.Logger].
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Printf(string, ...any) {}
+
 type options struct {
-	matchFunc func(string) bool
+	includes        []func(string) bool
+	excludes        []func(string) bool
+	filters         []func(string) bool
+	parser          Parser
+	prefix          string
+	revision        string
+	target          Target
+	bump            bool
+	meta            string
+	initial         string
+	selection       Selection
+	shallowFallback bool
+	maxDepth        int
+	exactMatch      bool
+	requireTag      bool
+	line            *ReleaseLine
+	lineFromBranch  bool
+	lineFallback    string
+	open            OpenBackend
+	checkDirty      bool
+	cache           bool
+	cacheKeys       []string
+	uncacheable     bool
+	logger          Logger
+	err             error
+}
+
+// fail records the first error that occurs while applying the options.
+func (o *options) fail(err error) {
+	if o.err == nil {
+		o.err = err
+	}
 }
 
 type Option = func(*options)
 
-func WithMatchPattern(pattern string) Option {
+// WithDirtyCheck enables the inspection of the worktree. If it contains uncommitted
+// changes to tracked files, the Dirty flag of the resulting [RepoHead] will be set.
+func WithDirtyCheck() Option {
 	return func(opts *options) {
-		opts.matchFunc = func(tagName string) bool {
-			if pattern == "" {
-				return true
-			}
-			matched, err := filepath.Match(pattern, tagName)
-			if err != nil {
-				fmt.Printf("Ignoring invalid match pattern: %s: %s\n", pattern, err)
-				return true
+		opts.checkDirty = true
+	}
+}
+
+// WithLogger sets the logger that receives diagnostic messages. By default they are discarded.
+func WithLogger(logger Logger) Option {
+	return func(opts *options) {
+		opts.logger = logger
+	}
+}
+
+// WithReleaseLine limits the tags that are being considered to the ones belonging to the
+// given release line. If no tag is found with [WithRequireTag], the returned error names the
+// release line.
+func WithReleaseLine(line ReleaseLine) Option {
+	return func(opts *options) {
+		opts.line = &line
+		opts.record("release-line", line.String())
+		opts.filters = append(opts.filters, func(tagName string) bool {
+			// The parser might be set by a later option, so it is looked up on every call.
+			if opts.parser == nil {
+				return line.matches(tagName)
 			}
-			return matched
+			v, err := opts.parser.Parse(tagName)
+			return err == nil && line.Contains(v)
+		})
+	}
+}
+
+// WithBranchReleaseLine works like [WithReleaseLine], but derives the release line from the name
+// of the checked-out branch with [ReleaseLineFromBranch]. If the head is detached or a revision
+// is described, the fallback branch is used instead, e.g. the one reported by the CI environment.
+func WithBranchReleaseLine(fallback string) Option {
+	return func(opts *options) {
+		opts.lineFromBranch = true
+		opts.lineFallback = fallback
+	}
+}
+
+// WithParser sets the parser that is used to select and order the tags. By default all tags that
+// are valid semantic versions are considered, optionally with a prefix. With a parser, the tags
+// that it fails to parse are ignored instead, unless include patterns are given.
+func WithParser(parser Parser) Option {
+	return func(opts *options) {
+		opts.parser = parser
+		if key, ok := parserKey(parser); ok {
+			opts.record("parser", key)
+		} else {
+			opts.uncacheable = true
 		}
 	}
 }
 
+// WithInitialVersion sets the version that is used as base if no tag matches, e.g. 0.1.0, instead
+// of 0.0.0. Like the default, it is treated as the version of a virtual parent of the root commit,
+// so that the root commit itself is one commit ahead of it. Tags with one or two components are
+// accepted as well. An invalid version results in an error wrapping [ErrInvalidOption].
+func WithInitialVersion(initial string) Option {
+	return func(opts *options) {
+		if _, err := LenientParser("").Parse(initial); err != nil {
+			opts.fail(fmt.Errorf("%w: initial version %q isn't a version", ErrInvalidOption, initial))
+			return
+		}
+		opts.initial = initial
+		opts.record("initial", initial)
+	}
+}
+
+// WithShallowFallback allows describing shallow clones whose history ends before a matching tag
+// is reached. Instead of failing with [ErrShallowClone], the highest matching tag that isn't newer
+// than the commit at the shallow boundary is used and the Shallow flag of the [RepoHead] is set.
+// The distance is then only a lower bound. The tag might still belong to another branch.
+func WithShallowFallback() Option {
+	return func(opts *options) {
+		opts.shallowFallback = true
+		opts.record("shallow-fallback", "")
+	}
+}
+
+// WithRevision describes the given revision, e.g. a branch, tag or commit hash, instead of the
+// head commit. The branch of the resulting [RepoHead] is empty and the worktree isn't checked for
+// uncommitted changes. If the revision can't be resolved, [ErrUnknownRevision] is returned.
+func WithRevision(revision string) Option {
+	return func(opts *options) {
+		opts.revision = revision
+	}
+}
+
 // GitDescribe looks at the git repository at path and figures
 // out versioning relvant information about the head commit.
 func GitDescribe(path string, opts ...Option) (*RepoHead, error) {
-	options := options{matchFunc: func(version string) bool {
-		return semver.IsValid(ensurePrefix(version))
-	}}
-	for _, apply := range opts {
-		apply(&options)
-	}
+	return GitDescribeContext(context.Background(), path, opts...)
+}
 
-	openOpts := git.PlainOpenOptions{DetectDotGit: true}
-	repo, err := git.PlainOpenWithOptions(path, &openOpts)
+// GitDescribeContext is like [GitDescribe], but stops listing tags and walking the history once
+// ctx is done, in which case ctx.Err() is returned.
+func GitDescribeContext(ctx context.Context, path string, opts ...Option) (*RepoHead, error) {
+	options, err := newOptions(opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open repo: %w", err)
+		return nil, err
 	}
-	head, err := repo.Head()
+	backend, err := options.open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBackend(backend)
+	return describe(ctx, backend, options)
+}
+
+// GitDescribeRepository works like [GitDescribe] but operates on an already opened go-git
+// repository, so that repositories with custom storers like [memory.NewStorage] can be described
+// as well. The [WithBackend] option has no effect.
+//
+// [memory.NewStorage]: https://pkg.go.dev/github.com/go-git/go-git/v5/storage/memory#NewStorage
+func GitDescribeRepository(repo *git.Repository, opts ...Option) (*RepoHead, error) {
+	options, err := newOptions(opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve repo head: %w", err)
+		return nil, err
+	}
+	backend := newGoGitBackend(repo)
+	defer closeBackend(backend)
+	return describe(context.Background(), backend, options)
+}
+
+func newOptions(opts []Option) (*options, error) {
+	options := options{logger: discardLogger{}, open: OpenGoGit}
+	for _, apply := range opts {
+		apply(&options)
+	}
+	if options.err != nil {
+		return nil, options.err
 	}
+	if options.parser == nil && options.prefix != "" {
+		options.parser = PrefixParser(options.prefix)
+	}
+	return &options, nil
+}
 
-	ref := RepoHead{
-		Hash: head.Hash().String(),
+func describe(ctx context.Context, backend Backend, options *options) (*RepoHead, error) {
+	var ref RepoHead
+	var err error
+	if options.revision != "" {
+		ref.Hash, err = backend.Resolve(options.revision)
+	} else {
+		ref.Hash, ref.Branch, err = backend.Head()
 	}
-	tags, err := getTagMap(repo, options.matchFunc)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve tag-list: %w", err)
+		return nil, err
+	}
+	if options.lineFromBranch {
+		branch := ref.Branch
+		if branch == "" {
+			branch = options.lineFallback
+		}
+		var line ReleaseLine
+		if line, err = ReleaseLineFromBranch(branch); err != nil {
+			return nil, err
+		}
+		WithReleaseLine(line)(options)
+	}
+	if options.checkDirty && options.revision == "" {
+		ref.Dirty, err = backend.Dirty()
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve worktree status: %w", err)
+		}
 	}
+	cache := openCache(backend, options, ref.Hash)
+	if cache.load(&ref) {
+		return &ref, nil
+	}
+	if err = findTag(ctx, backend, options, &ref); err != nil {
+		if options.line != nil && errors.Is(err, ErrNoMatchingTag) {
+			return nil, fmt.Errorf("%w in release line %s", err, options.line)
+		}
+		return nil, err
+	}
+	cache.store(&ref)
+	return &ref, nil
+}
+
+// findTag walks the history from the commit of ref until the nearest matching tag is found and
+// records it together with the distance in ref.
+func findTag(ctx context.Context, backend Backend, options *options, ref *RepoHead) error {
+	tags, highest, err := getTagMap(ctx, backend, options)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	} else if err != nil {
+		return fmt.Errorf("failed to retrieve tag-list: %w", err)
+	}
+	ref.HighestTag = highest
 
 	if tag, found := tags[ref.Hash]; found {
-		ref.LastTag = tag.Name
-		return &ref, nil
+		ref.setTag(tag)
+		return nil
+	}
+	if options.exactMatch {
+		return fmt.Errorf("%w: head commit %s is not tagged", ErrNoMatchingTag, ref.Hash)
 	}
 
-	commits, err := repo.Log(&git.LogOptions{
-		From:  head.Hash(),
-		Order: git.LogOrderCommitterTime,
-	})
+	shallowCommits, err := backend.Shallow()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list commits: %w", err)
+		return fmt.Errorf("failed to read shallow commits: %w", err)
 	}
-
-	_ = commits.ForEach(func(c *object.Commit) error {
-		tag, ok := tags[c.Hash.String()]
+	shallow := make(map[string]bool, len(shallowCommits))
+	for _, hash := range shallowCommits {
+		shallow[hash] = true
+	}
+	var reachable *reachability
+	if options.requireTag {
+		if len(tags) == 0 {
+			return ErrNoMatchingTag
+		}
+		reachable = newReachability(backend, tags)
+	}
+	var boundary Commit
+	tooDeep := false
+	err = backend.Walk(ctx, ref.Hash, func(commit Commit) error {
+		tag, ok := tags[commit.Hash]
 		if ok {
-			ref.LastTag = tag.Name
-			return storer.ErrStop
+			ref.setTag(tag)
+			return ErrStopWalk
+		}
+		if options.maxDepth > 0 && ref.CommitsSinceTag == options.maxDepth {
+			tooDeep = true
+			return ErrStopWalk
 		}
 		ref.CommitsSinceTag++
+		if shallow[commit.Hash] {
+			// The parents of the boundary are missing, but a tag might still be reachable on
+			// other paths, e.g. through the second parent of a merge.
+			if boundary.Hash == "" || commit.When.After(boundary.When) {
+				boundary = commit
+			}
+			commit.Parents = nil
+		}
+		if reachable != nil && !reachable.visit(commit) {
+			return ErrStopWalk
+		}
 		return nil
 	})
-	return &ref, nil
+	if ctx.Err() != nil {
+		return ctx.Err()
+	} else if err != nil {
+		return fmt.Errorf("failed to list commits: %w", err)
+	}
+	if tooDeep {
+		return &DepthError{Depth: options.maxDepth}
+	}
+	if boundary.Hash != "" && ref.LastTag == "" {
+		if !options.shallowFallback {
+			return fmt.Errorf(
+				"%w: no tag found before the shallow boundary at %s, fetch more history with git fetch --unshallow",
+				ErrShallowClone,
+				boundary.Hash,
+			)
+		}
+		ref.Shallow = true
+		if tag, found := highestTag(tags, boundary.When, options); found {
+			ref.setTag(tag)
+		}
+		options.logger.Printf(
+			"Reached the shallow boundary at %s, the distance of %d commits is a lower bound",
+			boundary.Hash,
+			ref.CommitsSinceTag,
+		)
+	}
+	if ref.LastTag == "" && options.requireTag {
+		return ErrNoMatchingTag
+	}
+	if ref.LastTag == "" && options.initial != "" {
+		ref.InitialVersion = options.initial
+	}
+	return nil
+}
+
+// highestTag returns the highest of the tags that aren't newer than before. Tags that are newer
+// than the shallow boundary were created on another branch, unless the clocks were skewed.
+func highestTag(tags map[string]Tag, before time.Time, opts *options) (Tag, bool) {
+	var (
+		result Tag
+		found  bool
+	)
+	for _, tag := range tags {
+		if tag.When.After(before) {
+			continue
+		}
+		if !found || opts.compare(result.Name, tag.Name) < 0 {
+			result = tag
+			found = true
+		}
+	}
+	return result, found
 }
 
 type Tag struct {
-	Name string
-	When time.Time
+	Name      string
+	When      time.Time
+	Annotated bool
+	Signed    bool
+}
+
+func (h *RepoHead) setTag(tag Tag) {
+	h.LastTag = tag.Name
+	h.Annotated = tag.Annotated
+	h.Signed = tag.Signed
 }
 
-func getTagMap(repo *git.Repository, match func(string) bool) (map[string]Tag, error) {
-	tags, err := repo.Tags()
+// getTagMap maps commit hashes to the matching tag pointing to them. If several tags point to the
+// same commit, the selection policy decides which one is used. Additionally the name of the
+// highest matching tag is returned.
+func getTagMap(ctx context.Context, backend Backend, opts *options) (map[string]Tag, string, error) {
+	tags, err := backend.Tags(ctx, opts.match)
 	if err != nil {
-		return nil, err
+		return nil, "", fmt.Errorf("failed to list tags: %w", err)
 	}
+	var highest string
 	result := make(map[string]Tag)
-	if err = tags.ForEach(func(ref *plumbing.Reference) error {
-		tag, err := repo.TagObject(ref.Hash())
-		switch err {
-		case nil:
-			commit, err := tag.Commit()
-			if err != nil {
-				return nil
-			}
-			hash := commit.Hash.String()
-			if t, ok := result[hash]; ok && !tag.Tagger.When.After(t.When) {
-				return nil
-			}
-			if match(tag.Name) {
-				result[hash] = Tag{Name: tag.Name, When: tag.Tagger.When}
-			}
-		case plumbing.ErrObjectNotFound:
-			tagName := ref.Name().Short()
-			if !match(tagName) {
-				return nil
-			}
-			commit, err := repo.CommitObject(ref.Hash())
-			if err != nil {
-				return nil
-			}
-			hash := commit.Hash.String()
-			existing, ok := result[hash]
-			if !ok {
-				result[hash] = Tag{Name: tagName, When: commit.Committer.When}
-				return nil
-			}
-
-			if semver.Compare(ensurePrefix(existing.Name), ensurePrefix(tagName)) < 0 {
-				result[hash] = Tag{Name: tagName, When: commit.Committer.When}
-			}
-			return nil
-		default:
-			return err
+	for _, tag := range tags {
+		if err = ctx.Err(); err != nil {
+			return nil, "", err
 		}
-		return nil
-	}); err != nil {
-		return nil, fmt.Errorf("failed to list tags: %w", err)
+		if tag.Err != nil {
+			opts.logger.Printf("Ignoring tag %s: %s", tag.Name, tag.Err)
+			continue
+		}
+		if highest == "" || opts.compare(highest, tag.Name) < 0 {
+			highest = tag.Name
+		}
+		if existing, ok := result[tag.Commit]; ok && !opts.prefer(tag.Tag, existing) {
+			continue
+		}
+		result[tag.Commit] = tag.Tag
 	}
-	return result, nil
+	return result, highest, nil
 }
@@ -1,129 +1,749 @@
 package version
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// testBackends runs the test with each history backend.
+func testBackends(t *testing.T, test func(t *testing.T, backend Option)) {
+	t.Helper()
+	for _, backend := range []struct {
+		name string
+		open OpenBackend
+	}{
+		{"go-git", OpenGoGit},
+		{"git", OpenGitCLI},
+	} {
+		t.Run(backend.name, func(t *testing.T) {
+			if backend.name == "git" {
+				if _, err := exec.LookPath("git"); err != nil {
+					t.Skip("git binary not found")
+				}
+			}
+			test(t, WithBackend(backend.open))
+		})
+	}
+}
+
 func TestGitDescribe(t *testing.T) {
-	assert := assert.New(t)
-	require := require.New(t)
-	dir, _ := os.MkdirTemp("", "example")
-	repo, err := git.PlainInit(dir, false)
-	require.NoError(err)
+	testBackends(t, func(t *testing.T, backend Option) {
+		assert := assert.New(t)
+		require := require.New(t)
+		dir, _ := os.MkdirTemp("", "example")
+		repo, err := git.PlainInit(dir, false)
+		require.NoError(err)
 
-	worktree, err := repo.Worktree()
-	require.NoError(err)
+		worktree, err := repo.Worktree()
+		require.NoError(err)
+
+		test := func(expected *RepoHead, opts ...Option) {
+			actual, err := GitDescribe(dir, append(opts, backend)...)
+			require.NoError(err)
+			assert.Equal(expected, actual)
+		}
 
-	test := func(expected *RepoHead, opts ...Option) {
-		actual, err := GitDescribe(dir, opts...)
+		now := time.Now().UTC()
+		author := &object.Signature{
+			Name:  "John Doe",
+			Email: "john@doe.org",
+			When:  now,
+		}
+		opts := git.CommitOptions{
+			Author:            author,
+			Committer:         author,
+			AllowEmptyCommits: true,
+		}
+
+		commit1, err := worktree.Commit("first commit", &opts)
 		require.NoError(err)
-		assert.Equal(expected, actual)
-	}
+		test(&RepoHead{Hash: commit1.String(), Branch: "master", CommitsSinceTag: 1})
+
+		tag1, err := repo.CreateTag("1.0.0", commit1, nil)
+		require.NoError(err)
+		test(&RepoHead{
+			LastTag:         tag1.Name().Short(),
+			HighestTag:      "1.0.0",
+			Branch:          "master",
+			Hash:            commit1.String(),
+			CommitsSinceTag: 0,
+		})
+
+		author.When = author.When.Add(1 * time.Hour)
+		tag1Post, err := repo.CreateTag("v1.0.1", commit1, &git.CreateTagOptions{
+			Tagger:  author,
+			Message: "annotated tag",
+		})
+		require.NoError(err)
+		test(&RepoHead{
+			LastTag:         tag1Post.Name().Short(),
+			HighestTag:      "v1.0.1",
+			Branch:          "master",
+			Hash:            commit1.String(),
+			CommitsSinceTag: 0,
+			Annotated:       true,
+		})
+
+		test(&RepoHead{
+			LastTag:         tag1.Name().Short(),
+			HighestTag:      "1.0.0",
+			Branch:          "master",
+			Hash:            commit1.String(),
+			CommitsSinceTag: 0,
+		}, WithMatchPattern("1.*.*"))
+
+		author.When = author.When.Add(1 * time.Hour)
+		commit2, err := worktree.Commit("second commit", &opts)
+		require.NoError(err)
+		test(&RepoHead{
+			LastTag:         tag1Post.Name().Short(),
+			HighestTag:      "v1.0.1",
+			Branch:          "master",
+			Hash:            commit2.String(),
+			CommitsSinceTag: 1,
+			Annotated:       true,
+		})
 
-	now := time.Now().UTC()
-	author := &object.Signature{
-		Name:  "John Doe",
-		Email: "john@doe.org",
-		When:  now,
+		author.When = author.When.Add(1 * time.Second)
+		tag2, err := repo.CreateTag("v2.0.0-rc.1", commit2, &git.CreateTagOptions{
+			Tagger:  author,
+			Message: "looks like the final release",
+		})
+		require.NoError(err)
+		test(&RepoHead{
+			LastTag:         tag2.Name().Short(),
+			HighestTag:      "v2.0.0-rc.1",
+			Branch:          "master",
+			Hash:            commit2.String(),
+			CommitsSinceTag: 0,
+			Annotated:       true,
+		})
+
+		author.When = author.When.Add(1 * time.Second)
+		tag3, err := repo.CreateTag("v2.0.0", commit2, &git.CreateTagOptions{
+			Tagger:  author,
+			Message: "the final release",
+		})
+		require.NoError(err)
+		test(&RepoHead{
+			LastTag:         tag3.Name().Short(),
+			HighestTag:      "v2.0.0",
+			Branch:          "master",
+			Hash:            commit2.String(),
+			CommitsSinceTag: 0,
+			Annotated:       true,
+		})
+
+		err = worktree.Checkout(&git.CheckoutOptions{Hash: commit1})
+		require.NoError(err)
+		test(&RepoHead{
+			LastTag:         tag1Post.Name().Short(),
+			HighestTag:      "v2.0.0",
+			Hash:            commit1.String(),
+			CommitsSinceTag: 0,
+			Annotated:       true,
+		})
+		err = worktree.Checkout(&git.CheckoutOptions{Branch: "refs/heads/master"})
+		require.NoError(err)
+
+		dir += "/subfoler"
+		err = os.Mkdir(dir, 0750)
+		require.NoError(err)
+
+		test(&RepoHead{
+			LastTag:         tag3.Name().Short(),
+			HighestTag:      "v2.0.0",
+			Branch:          "master",
+			Hash:            commit2.String(),
+			CommitsSinceTag: 0,
+			Annotated:       true,
+		})
+	})
+}
+
+func TestGitDescribeError(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "example")
+
+	test := func(msg string, sentinel, cause error) {
+		head, err := GitDescribe(dir)
+		assert.Nil(t, head)
+		require.EqualError(t, err, msg)
+		require.ErrorIs(t, err, sentinel)
+		require.ErrorIs(t, err, cause)
 	}
-	opts := git.CommitOptions{
-		Author:            author,
-		Committer:         author,
+	test("failed to open repo: repository does not exist", ErrNotRepository, git.ErrRepositoryNotExists)
+
+	_, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	test("failed to retrieve repo head: reference not found", ErrNoHead, plumbing.ErrReferenceNotFound)
+}
+
+func TestGitDescribeRepository(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	require.NoError(t, err)
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+
+	signature := object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+	commit1, err := worktree.Commit("first commit", &git.CommitOptions{
+		Author:            &signature,
+		Committer:         &signature,
 		AllowEmptyCommits: true,
-	}
+	})
+	require.NoError(t, err)
+	_, err = repo.CreateTag("v1.0.0", commit1, nil)
+	require.NoError(t, err)
+	commit2, err := worktree.Commit("second commit", &git.CommitOptions{
+		Author:            &signature,
+		Committer:         &signature,
+		AllowEmptyCommits: true,
+	})
+	require.NoError(t, err)
+
+	head, err := GitDescribeRepository(repo, WithDirtyCheck())
+	require.NoError(t, err)
+	assert.Equal(t, &RepoHead{
+		LastTag:         "v1.0.0",
+		HighestTag:      "v1.0.0",
+		CommitsSinceTag: 1,
+		Hash:            commit2.String(),
+		Branch:          "master",
+	}, head)
+
+	_, err = GitDescribeRepository(repo, WithMatchPattern("v["))
+	require.ErrorIs(t, err, ErrInvalidPattern)
+
+	empty, err := git.Init(memory.NewStorage(), nil)
+	require.NoError(t, err)
+	_, err = GitDescribeRepository(empty)
+	require.ErrorIs(t, err, ErrNoHead)
+}
+
+// cancelAfterTags cancels the context once the tags are listed, so that the walk is cancelled.
+type cancelAfterTags struct {
+	Backend
+	cancel context.CancelFunc
+}
+
+func (b cancelAfterTags) Tags(ctx context.Context, filter func(tagName string) bool) ([]TagRef, error) {
+	defer b.cancel()
+	return b.Backend.Tags(ctx, filter)
+}
+
+func TestGitDescribeContext(t *testing.T) {
+	testBackends(t, func(t *testing.T, backend Option) {
+		dir := t.TempDir()
+		repo, err := git.PlainInit(dir, false)
+		require.NoError(t, err)
+		worktree, err := repo.Worktree()
+		require.NoError(t, err)
+		signature := object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+		for range 2 {
+			_, err = worktree.Commit("commit", &git.CommitOptions{
+				Author:            &signature,
+				Committer:         &signature,
+				AllowEmptyCommits: true,
+			})
+			require.NoError(t, err)
+		}
 
-	commit1, err := worktree.Commit("first commit", &opts)
-	require.NoError(err)
-	test(&RepoHead{Hash: commit1.String(), CommitsSinceTag: 1})
+		head, err := GitDescribeContext(context.Background(), dir, backend)
+		require.NoError(t, err)
+		assert.Equal(t, 2, head.CommitsSinceTag)
 
-	tag1, err := repo.CreateTag("1.0.0", commit1, nil)
-	require.NoError(err)
-	test(&RepoHead{
-		LastTag:         tag1.Name().Short(),
-		Hash:            commit1.String(),
-		CommitsSinceTag: 0,
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		head, err = GitDescribeContext(ctx, dir, backend)
+		assert.Nil(t, head)
+		assert.Equal(t, context.Canceled, err)
+
+		history, err := openBackend(t, backend, dir)
+		require.NoError(t, err)
+		ctx, cancel = context.WithCancel(context.Background())
+		open := func(string) (Backend, error) { return cancelAfterTags{history, cancel}, nil }
+		head, err = GitDescribeContext(ctx, dir, WithBackend(open))
+		assert.Nil(t, head)
+		assert.Equal(t, context.Canceled, err)
 	})
+}
+
+func TestGitDescribeDirty(t *testing.T) {
+	testBackends(t, func(t *testing.T, backend Option) {
+		dir, _ := os.MkdirTemp("", "example")
+		repo, err := git.PlainInit(dir, false)
+		require.NoError(t, err)
+		worktree, err := repo.Worktree()
+		require.NoError(t, err)
+
+		err = os.WriteFile(filepath.Join(dir, "README"), []byte("hello"), 0600)
+		require.NoError(t, err)
+		_, err = worktree.Add("README")
+		require.NoError(t, err)
+		signature := &object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+		_, err = worktree.Commit("first commit", &git.CommitOptions{Author: signature, Committer: signature})
+		require.NoError(t, err)
+
+		test := func(expected bool, opts ...Option) {
+			head, err := GitDescribe(dir, append(opts, backend)...)
+			require.NoError(t, err)
+			assert.Equal(t, expected, head.Dirty)
+		}
+		test(false, WithDirtyCheck())
 
-	author.When = author.When.Add(1 * time.Hour)
-	tag1Post, err := repo.CreateTag("v1.0.1", commit1, &git.CreateTagOptions{
-		Tagger:  author,
-		Message: "annotated tag",
+		err = os.WriteFile(filepath.Join(dir, "untracked"), []byte("ignored"), 0600)
+		require.NoError(t, err)
+		test(false, WithDirtyCheck())
+
+		err = os.WriteFile(filepath.Join(dir, "README"), []byte("changed"), 0600)
+		require.NoError(t, err)
+		test(true, WithDirtyCheck())
+		test(false)
 	})
-	require.NoError(err)
-	test(&RepoHead{
-		LastTag:         tag1Post.Name().Short(),
-		Hash:            commit1.String(),
-		CommitsSinceTag: 0,
+}
+
+func TestGitDescribeReleaseLine(t *testing.T) {
+	testBackends(t, func(t *testing.T, backend Option) {
+		dir, _ := os.MkdirTemp("", "example")
+		repo, err := git.PlainInit(dir, false)
+		require.NoError(t, err)
+		worktree, err := repo.Worktree()
+		require.NoError(t, err)
+
+		signature := &object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+		for _, tagName := range []string{"v1.4.0", "v1.4.1", "v1.5.0", ""} {
+			signature.When = signature.When.Add(time.Minute)
+			opts := git.CommitOptions{Author: signature, Committer: signature, AllowEmptyCommits: true}
+			hash, err := worktree.Commit("commit", &opts)
+			require.NoError(t, err)
+			if tagName != "" {
+				_, err = repo.CreateTag(tagName, hash, nil)
+				require.NoError(t, err)
+			}
+		}
+
+		head, err := GitDescribe(dir, backend, WithReleaseLine(ReleaseLine{Major: 1, Minor: 4}))
+		require.NoError(t, err)
+		assert.Equal(t, "v1.4.1", head.LastTag)
+		assert.Equal(t, 2, head.CommitsSinceTag)
+
+		head, err = GitDescribe(dir, backend, WithReleaseLine(ReleaseLine{Major: 2, Minor: 0}))
+		require.NoError(t, err)
+		assert.Empty(t, head.LastTag)
+		assert.Equal(t, 4, head.CommitsSinceTag)
+
+		_, err = GitDescribe(dir, backend, WithBranchReleaseLine("release/1.4"))
+		require.EqualError(t, err, `invalid release line: branch "master" doesn't name a release line`)
+		require.ErrorIs(t, err, ErrInvalidReleaseLine)
+
+		head, err = GitDescribe(dir, backend, WithBranchReleaseLine("release/1.4"), WithRevision("master"))
+		require.NoError(t, err)
+		assert.Equal(t, "v1.4.1", head.LastTag)
+
+		_, err = GitDescribe(dir, backend, WithBranchReleaseLine("release/2.0"), WithRevision("master"), WithRequireTag())
+		require.EqualError(t, err, "no matching tag in release line 2.0")
+		require.ErrorIs(t, err, ErrNoMatchingTag)
+
+		checkout := git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("release/1.4"), Create: true}
+		require.NoError(t, worktree.Checkout(&checkout))
+		head, err = GitDescribe(dir, backend, WithBranchReleaseLine("release/2.0"))
+		require.NoError(t, err)
+		assert.Equal(t, "v1.4.1", head.LastTag)
 	})
+}
 
-	test(&RepoHead{
-		LastTag:         tag1.Name().Short(),
-		Hash:            commit1.String(),
-		CommitsSinceTag: 0,
-	}, WithMatchPattern("1.*.*"))
-
-	author.When = author.When.Add(1 * time.Hour)
-	commit2, err := worktree.Commit("second commit", &opts)
-	require.NoError(err)
-	test(&RepoHead{
-		LastTag:         tag1Post.Name().Short(),
-		Hash:            commit2.String(),
-		CommitsSinceTag: 1,
+func TestGitDescribeExclude(t *testing.T) {
+	testBackends(t, func(t *testing.T, backend Option) {
+		dir := t.TempDir()
+		repo, err := git.PlainInit(dir, false)
+		require.NoError(t, err)
+		worktree, err := repo.Worktree()
+		require.NoError(t, err)
+
+		signature := &object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+		for _, tagName := range []string{"v1.0.0", "legacy-2.0.0", "v1.1.0-nightly.1"} {
+			signature.When = signature.When.Add(time.Minute)
+			opts := git.CommitOptions{Author: signature, Committer: signature, AllowEmptyCommits: true}
+			hash, err := worktree.Commit("commit", &opts)
+			require.NoError(t, err)
+			_, err = repo.CreateTag(tagName, hash, nil)
+			require.NoError(t, err)
+		}
+
+		head, err := GitDescribe(dir, backend, WithMatchPattern("*"), WithExcludePattern("*-nightly*"))
+		require.NoError(t, err)
+		assert.Equal(t, "legacy-2.0.0", head.LastTag)
+		assert.Equal(t, 1, head.CommitsSinceTag)
+
+		head, err = GitDescribe(dir, backend, WithExcludeRegexp(`-nightly\.\d+$`), WithExcludePattern("legacy-*"))
+		require.NoError(t, err)
+		assert.Equal(t, "v1.0.0", head.LastTag)
+		assert.Equal(t, 2, head.CommitsSinceTag)
 	})
+}
+
+func TestGitDescribeParser(t *testing.T) {
+	testBackends(t, func(t *testing.T, backend Option) {
+		dir := t.TempDir()
+		repo, err := git.PlainInit(dir, false)
+		require.NoError(t, err)
+		worktree, err := repo.Worktree()
+		require.NoError(t, err)
 
-	author.When = author.When.Add(1 * time.Second)
-	tag2, err := repo.CreateTag("v2.0.0-rc.1", commit2, &git.CreateTagOptions{
-		Tagger:  author,
-		Message: "looks like the final release",
+		signature := &object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+		for _, tagName := range []string{"build_1_2_3", "build_1_10_0", "v2.0.0", ""} {
+			signature.When = signature.When.Add(time.Minute)
+			opts := git.CommitOptions{Author: signature, Committer: signature, AllowEmptyCommits: true}
+			hash, err := worktree.Commit("commit", &opts)
+			require.NoError(t, err)
+			if tagName != "" {
+				_, err = repo.CreateTag(tagName, hash, nil)
+				require.NoError(t, err)
+			}
+		}
+		parser, err := NewRegexpParser(`build_(?P<major>\d+)_(?P<minor>\d+)_(?P<patch>\d+)`)
+		require.NoError(t, err)
+
+		head, err := GitDescribe(dir, backend, WithParser(parser))
+		require.NoError(t, err)
+		assert.Equal(t, "build_1_10_0", head.LastTag)
+		assert.Equal(t, "build_1_10_0", head.HighestTag)
+		assert.Equal(t, 2, head.CommitsSinceTag)
+
+		head, err = GitDescribe(dir, backend, WithReleaseLine(ReleaseLine{Major: 1, Minor: 2}), WithParser(parser))
+		require.NoError(t, err)
+		assert.Equal(t, "build_1_2_3", head.LastTag)
+		assert.Equal(t, 3, head.CommitsSinceTag)
 	})
-	require.NoError(err)
-	test(&RepoHead{
-		LastTag:         tag2.Name().Short(),
-		Hash:            commit2.String(),
-		CommitsSinceTag: 0,
+}
+
+func TestGitDescribeLenient(t *testing.T) {
+	testBackends(t, func(t *testing.T, backend Option) {
+		dir := t.TempDir()
+		repo, err := git.PlainInit(dir, false)
+		require.NoError(t, err)
+		worktree, err := repo.Worktree()
+		require.NoError(t, err)
+
+		signature := &object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+		for _, tagName := range []string{"v1.9.0", "v2.1", ""} {
+			signature.When = signature.When.Add(time.Minute)
+			opts := git.CommitOptions{Author: signature, Committer: signature, AllowEmptyCommits: true}
+			hash, err := worktree.Commit("commit", &opts)
+			require.NoError(t, err)
+			if tagName != "" {
+				_, err = repo.CreateTag(tagName, hash, nil)
+				require.NoError(t, err)
+			}
+		}
+
+		head, err := GitDescribe(dir, backend)
+		require.NoError(t, err)
+		assert.Equal(t, "v1.9.0", head.LastTag)
+		assert.Equal(t, "v1.9.0", head.HighestTag)
+
+		head, err = GitDescribe(dir, backend, WithParser(LenientParser("")))
+		require.NoError(t, err)
+		assert.Equal(t, "v2.1", head.LastTag)
+		assert.Equal(t, "v2.1", head.HighestTag)
+		assert.Equal(t, 1, head.CommitsSinceTag)
 	})
+}
 
-	author.When = author.When.Add(1 * time.Second)
-	tag3, err := repo.CreateTag("v2.0.0", commit2, &git.CreateTagOptions{
-		Tagger:  author,
-		Message: "the final release",
+func TestGitDescribeInitialVersion(t *testing.T) {
+	testBackends(t, func(t *testing.T, backend Option) {
+		dir := t.TempDir()
+		repo, err := git.PlainInit(dir, false)
+		require.NoError(t, err)
+		worktree, err := repo.Worktree()
+		require.NoError(t, err)
+
+		signature := &object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+		commit := func() {
+			signature.When = signature.When.Add(time.Minute)
+			opts := git.CommitOptions{Author: signature, Committer: signature, AllowEmptyCommits: true}
+			_, err := worktree.Commit("commit", &opts)
+			require.NoError(t, err)
+		}
+
+		commit()
+		head, err := GitDescribe(dir, backend, WithInitialVersion("1.0"))
+		require.NoError(t, err)
+		assert.Equal(t, "1.0", head.InitialVersion)
+		assert.Equal(t, 1, head.CommitsSinceTag)
+		ver, err := NewFromHead(head, "")
+		require.NoError(t, err)
+		formatted, err := ver.BumpTo(Devel).Format(NoMetaFormat)
+		require.NoError(t, err)
+		assert.Equal(t, "1.0.1-dev.1", formatted, "the root commit isn't a release")
+
+		commit()
+		commit()
+		head, err = GitDescribe(dir, backend, WithInitialVersion("v0.1.0"))
+		require.NoError(t, err)
+		assert.Empty(t, head.LastTag)
+		assert.Equal(t, 3, head.CommitsSinceTag)
+		ver, err = NewFromHead(head, "")
+		require.NoError(t, err)
+		formatted, err = ver.BumpTo(Devel).Format(NoMetaFormat)
+		require.NoError(t, err)
+		assert.Equal(t, "v0.1.1-dev.3", formatted)
+
+		head, err = GitDescribe(dir, backend)
+		require.NoError(t, err)
+		assert.Empty(t, head.InitialVersion)
+		assert.Equal(t, 3, head.CommitsSinceTag)
+
+		_, err = GitDescribe(dir, backend, WithInitialVersion("one"))
+		require.ErrorIs(t, err, ErrInvalidOption)
+		require.NotErrorIs(t, err, ErrInvalidTag)
 	})
-	require.NoError(err)
-	test(&RepoHead{
-		LastTag:         tag3.Name().Short(),
-		Hash:            commit2.String(),
-		CommitsSinceTag: 0,
+}
+
+func TestGitDescribeShallow(t *testing.T) {
+	testBackends(t, func(t *testing.T, backend Option) {
+		dir := t.TempDir()
+		repo, err := git.PlainInit(dir, false)
+		require.NoError(t, err)
+		worktree, err := repo.Worktree()
+		require.NoError(t, err)
+
+		signature := &object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+		var hashes []plumbing.Hash
+		for _, tagName := range []string{"v1.0.0", "", "", ""} {
+			signature.When = signature.When.Add(time.Minute)
+			opts := git.CommitOptions{Author: signature, Committer: signature, AllowEmptyCommits: true}
+			hash, err := worktree.Commit("commit", &opts)
+			require.NoError(t, err)
+			if tagName != "" {
+				_, err = repo.CreateTag(tagName, hash, nil)
+				require.NoError(t, err)
+			}
+			hashes = append(hashes, hash)
+		}
+
+		require.NoError(t, repo.Storer.SetShallow([]plumbing.Hash{hashes[0]}))
+		head, err := GitDescribe(dir, backend)
+		require.NoError(t, err)
+		assert.Equal(t, "v1.0.0", head.LastTag)
+		assert.Equal(t, 3, head.CommitsSinceTag)
+		assert.False(t, head.Shallow)
+
+		require.NoError(t, repo.Storer.SetShallow([]plumbing.Hash{hashes[2]}))
+		_, err = GitDescribe(dir, backend)
+		require.ErrorIs(t, err, ErrShallowClone)
+		assert.Contains(t, err.Error(), "no tag found before the shallow boundary at "+hashes[2].String())
+
+		var logger testLogger
+		head, err = GitDescribe(dir, backend, WithShallowFallback(), WithLogger(&logger))
+		require.NoError(t, err)
+		assert.Equal(t, "v1.0.0", head.LastTag)
+		assert.Equal(t, 2, head.CommitsSinceTag)
+		assert.True(t, head.Shallow)
+		assert.Len(t, logger.messages, 1)
+
+		checkout := git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true}
+		require.NoError(t, worktree.Checkout(&checkout))
+		signature.When = signature.When.Add(time.Minute)
+		opts := git.CommitOptions{Author: signature, Committer: signature, AllowEmptyCommits: true}
+		hash, err := worktree.Commit("commit", &opts)
+		require.NoError(t, err)
+		_, err = repo.CreateTag("v2.0.0", hash, nil)
+		require.NoError(t, err)
+		checkout = git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("master")}
+		require.NoError(t, worktree.Checkout(&checkout))
+		head, err = GitDescribe(dir, backend, WithShallowFallback())
+		require.NoError(t, err)
+		assert.Equal(t, "v1.0.0", head.LastTag, "tags newer than the shallow boundary are ignored")
+		assert.Equal(t, "v2.0.0", head.HighestTag)
+
+		require.NoError(t, repo.DeleteTag("v1.0.0"))
+		head, err = GitDescribe(dir, backend, WithShallowFallback())
+		require.NoError(t, err)
+		assert.Empty(t, head.LastTag)
+		assert.True(t, head.Shallow)
+		t.Run("tag behind a merge", func(t *testing.T) {
+			dir := t.TempDir()
+			repo, err := git.PlainInit(dir, false)
+			require.NoError(t, err)
+			tree := repo.Storer.NewEncodedObject()
+			require.NoError(t, (&object.Tree{}).Encode(tree))
+			treeHash, err := repo.Storer.SetEncodedObject(tree)
+			require.NoError(t, err)
+			signature := object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Unix(1715601600, 0)}
+			commit := func(parents ...plumbing.Hash) plumbing.Hash {
+				signature.When = signature.When.Add(time.Minute)
+				obj := repo.Storer.NewEncodedObject()
+				require.NoError(t, (&object.Commit{
+					Author:       signature,
+					Committer:    signature,
+					Message:      "commit",
+					TreeHash:     treeHash,
+					ParentHashes: parents,
+				}).Encode(obj))
+				hash, err := repo.Storer.SetEncodedObject(obj)
+				require.NoError(t, err)
+				return hash
+			}
+			// m1 - m2 - m3 - merge
+			//   \           /
+			//    f1 ----- f2
+			root := commit()
+			feature := commit(root)
+			boundary := commit(root)
+			merge := commit(commit(boundary), commit(feature))
+			_, err = repo.CreateTag("v1.1.0", feature, nil)
+			require.NoError(t, err)
+			require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference("refs/heads/master", merge)))
+			require.NoError(t, repo.Storer.SetShallow([]plumbing.Hash{boundary, feature}))
+
+			head, err := GitDescribe(dir, backend)
+			require.NoError(t, err)
+			assert.Equal(t, "v1.1.0", head.LastTag)
+			assert.Equal(t, 4, head.CommitsSinceTag)
+			assert.False(t, head.Shallow)
+		})
 	})
+}
 
-	dir += "/subfoler"
-	err = os.Mkdir(dir, 0750)
-	require.NoError(err)
+func TestGitDescribeSignedTag(t *testing.T) {
+	testBackends(t, func(t *testing.T, backend Option) {
+		dir, _ := os.MkdirTemp("", "example")
+		repo, err := git.PlainInit(dir, false)
+		require.NoError(t, err)
+		worktree, err := repo.Worktree()
+		require.NoError(t, err)
 
-	test(&RepoHead{
-		LastTag:         tag3.Name().Short(),
-		Hash:            commit2.String(),
-		CommitsSinceTag: 0,
+		signature := object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+		hash, err := worktree.Commit("commit", &git.CommitOptions{
+			Author:            &signature,
+			Committer:         &signature,
+			AllowEmptyCommits: true,
+		})
+		require.NoError(t, err)
+
+		tag := object.Tag{
+			Name:         "v1.0.0",
+			Tagger:       signature,
+			Message:      "signed release\n",
+			TargetType:   plumbing.CommitObject,
+			Target:       hash,
+			PGPSignature: "-----BEGIN PGP SIGNATURE-----\n\nabc\n-----END PGP SIGNATURE-----\n",
+		}
+		obj := repo.Storer.NewEncodedObject()
+		require.NoError(t, tag.Encode(obj))
+		tagHash, err := repo.Storer.SetEncodedObject(obj)
+		require.NoError(t, err)
+		err = repo.Storer.SetReference(plumbing.NewHashReference("refs/tags/v1.0.0", tagHash))
+		require.NoError(t, err)
+
+		head, err := GitDescribe(dir, backend)
+		require.NoError(t, err)
+		assert.Equal(t, "v1.0.0", head.LastTag)
+		assert.True(t, head.Annotated)
+		assert.True(t, head.Signed)
 	})
 }
 
-func TestGitDescribeError(t *testing.T) {
-	dir, _ := os.MkdirTemp("", "example")
+func TestGitDescribeInvalidPattern(t *testing.T) {
+	_, err := GitDescribe("../", WithMatchPattern("v["))
+	require.ErrorIs(t, err, ErrInvalidPattern)
+	require.ErrorIs(t, err, filepath.ErrBadPattern)
+	assert.Equal(t, `invalid match pattern "v[": syntax error in pattern`, err.Error())
+}
 
-	test := func(msg string) {
-		head, err := GitDescribe(dir)
-		assert.Nil(t, head)
-		require.EqualError(t, err, msg)
-	}
-	test("failed to open repo: repository does not exist")
+type testLogger struct {
+	messages []string
+}
 
-	_, err := git.PlainInit(dir, false)
+func (l *testLogger) Printf(format string, v ...any) {
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func TestGitDescribeLogger(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+
+	signature := object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+	_, err = worktree.Commit("commit", &git.CommitOptions{
+		Author:            &signature,
+		Committer:         &signature,
+		AllowEmptyCommits: true,
+	})
+	require.NoError(t, err)
+
+	blob := repo.Storer.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	blobHash, err := repo.Storer.SetEncodedObject(blob)
 	require.NoError(t, err)
-	test("failed to retrieve repo head: reference not found")
+	err = repo.Storer.SetReference(plumbing.NewHashReference("refs/tags/v1.0.0", blobHash))
+	require.NoError(t, err)
+
+	var logger testLogger
+	head, err := GitDescribe(dir, WithLogger(&logger))
+	require.NoError(t, err)
+	assert.Empty(t, head.LastTag)
+	assert.Equal(t, []string{"Ignoring tag v1.0.0: " + blobHash.String() + " doesn't point to a commit"}, logger.messages)
+}
+
+func TestExactMatch(t *testing.T) {
+	for _, test := range []struct {
+		head      RepoHead
+		annotated bool
+		signed    bool
+		err       string
+	}{
+		{
+			head: RepoHead{LastTag: "1.0.0"},
+		},
+		{
+			head: RepoHead{LastTag: "1.0.0", CommitsSinceTag: 2, Hash: "fcf2c8fa"},
+			err:  "no matching tag: head commit fcf2c8fa is not tagged",
+		},
+		{
+			head: RepoHead{CommitsSinceTag: 2, Hash: "fcf2c8fa"},
+			err:  "no matching tag: head commit fcf2c8fa is not tagged",
+		},
+		{
+			head:      RepoHead{LastTag: "1.0.0"},
+			annotated: true,
+			err:       "no matching tag: tag 1.0.0 is not annotated",
+		},
+		{
+			head:      RepoHead{LastTag: "1.0.0", Annotated: true},
+			annotated: true,
+		},
+		{
+			head:   RepoHead{LastTag: "1.0.0", Annotated: true},
+			signed: true,
+			err:    "no matching tag: tag 1.0.0 is not signed",
+		},
+		{
+			head:   RepoHead{LastTag: "1.0.0", Annotated: true, Signed: true},
+			signed: true,
+		},
+	} {
+		err := test.head.ExactMatch(test.annotated, test.signed)
+		if test.err == "" {
+			require.NoError(t, err)
+		} else {
+			require.ErrorIs(t, err, ErrNoMatchingTag)
+			require.EqualError(t, err, test.err)
+		}
+	}
 }
@@ -0,0 +1,220 @@
+package version
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type gitCLIBackend struct {
+	dir string
+}
+
+// OpenGitCLI opens the repository at path or any of its parent directories with the git binary
+// found in PATH. It supports every repository feature of the installed git version and is
+// usually faster than go-git for large histories.
+func OpenGitCLI(path string) (Backend, error) {
+	backend := gitCLIBackend{dir: path}
+	if _, err := backend.run("rev-parse", "--git-dir"); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotRepository, err)
+	}
+	return backend, nil
+}
+
+func (b gitCLIBackend) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", args...) // nolint: gosec
+	cmd.Dir = b.dir
+	cmd.Env = append(os.Environ(), "GIT_OPTIONAL_LOCKS=0")
+	return cmd
+}
+
+func (b gitCLIBackend) run(args ...string) (string, error) {
+	return b.runContext(context.Background(), args...)
+}
+
+// runContext runs git and returns its output. The process is killed once ctx is done, in which
+// case ctx.Err() is returned.
+func (b gitCLIBackend) runContext(ctx context.Context, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := b.command(ctx, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	} else if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (b gitCLIBackend) Head() (string, string, error) {
+	hash, err := b.run("rev-parse", "--verify", "--quiet", "HEAD^{commit}")
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrNoHead, err)
+	}
+	branch, err := b.run("symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		// The head is detached.
+		return hash, "", nil
+	}
+	return hash, branch, nil
+}
+
+func (b gitCLIBackend) Resolve(revision string) (string, error) {
+	hash, err := b.run("rev-parse", "--verify", "--quiet", "--end-of-options", revision+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("%w %s: %w", ErrUnknownRevision, revision, err)
+	}
+	return hash, nil
+}
+
+// tagFormat lists the fields of a tag separated by NUL characters. For annotated tags the
+// fields prefixed with * describe the tagged object.
+const tagFormat = "%(refname:strip=2)%00%(objecttype)%00%(objectname)%00%(*objecttype)%00%(*objectname)" +
+	"%00%(creatordate:unix)%00%(if)%(contents:signature)%(then)signed%(end)"
+
+func (b gitCLIBackend) Tags(ctx context.Context, filter func(tagName string) bool) ([]TagRef, error) {
+	out, err := b.runContext(ctx, "for-each-ref", "--format="+tagFormat, "refs/tags")
+	if err != nil {
+		return nil, err
+	}
+	var result []TagRef
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("unexpected output of git for-each-ref: %q", line)
+		}
+		name, objectType, object, targetType, target, date, signed :=
+			fields[0], fields[1], fields[2], fields[3], fields[4], fields[5], fields[6]
+		if !filter(name) {
+			continue
+		}
+		tagRef := TagRef{Tag: Tag{Name: name}}
+		if date != "" {
+			timestamp, err := strconv.ParseInt(date, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date of tag %s: %w", name, err)
+			}
+			tagRef.When = time.Unix(timestamp, 0)
+		}
+		switch {
+		case objectType == "commit":
+			tagRef.Commit = object
+		case objectType == "tag" && targetType == "commit":
+			tagRef.Commit = target
+			tagRef.Annotated = true
+			tagRef.Signed = signed != ""
+		case objectType == "tag" && targetType == "tag":
+			// for-each-ref only peels one level, so nested tags are resolved separately.
+			tagRef.Commit, err = b.runContext(ctx, "rev-parse", "--verify", "--quiet", object+"^{commit}")
+			if err != nil {
+				tagRef.Err = fmt.Errorf("%s doesn't point to a commit", object)
+			}
+			tagRef.Annotated = true
+			tagRef.Signed = signed != ""
+		default:
+			tagRef.Err = fmt.Errorf("%s doesn't point to a commit", object)
+		}
+		result = append(result, tagRef)
+	}
+	return result, nil
+}
+
+// Walk lists the commits with git rev-list in date order. The process is killed once the walk is
+// stopped, so that only the visited part of the history is read.
+func (b gitCLIBackend) Walk(ctx context.Context, hash string, visit func(Commit) error) error {
+	var stderr bytes.Buffer
+	cmd := b.command(ctx, "rev-list", "--date-order", "--parents", "--timestamp", hash)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	stop := func(err error) error {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return err
+	}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if err = ctx.Err(); err != nil {
+			return stop(err)
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			return stop(fmt.Errorf("unexpected output of git rev-list: %q", scanner.Text()))
+		}
+		timestamp, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return stop(fmt.Errorf("unexpected output of git rev-list: %w", err))
+		}
+		commit := Commit{Hash: fields[1], Parents: fields[2:], When: time.Unix(timestamp, 0)}
+		if err = visit(commit); errors.Is(err, ErrStopWalk) {
+			return stop(nil)
+		} else if err != nil {
+			return stop(err)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return stop(err)
+	}
+	if err = cmd.Wait(); ctx.Err() != nil {
+		return ctx.Err()
+	} else if err != nil {
+		return fmt.Errorf("git rev-list: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (b gitCLIBackend) Shallow() ([]string, error) {
+	path, err := b.run("rev-parse", "--git-path", "shallow")
+	if err != nil {
+		return nil, err
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(b.dir, path)
+	}
+	content, err := os.ReadFile(path) // nolint: gosec
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(content)), nil
+}
+
+func (b gitCLIBackend) GitDir() (string, error) {
+	return b.run("rev-parse", "--absolute-git-dir")
+}
+
+func (b gitCLIBackend) Dirty() (bool, error) {
+	bare, err := b.run("rev-parse", "--is-bare-repository")
+	if err != nil {
+		return false, err
+	}
+	if bare == "true" {
+		return false, nil
+	}
+	status, err := b.run("status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return false, err
+	}
+	return status != "", nil
+}
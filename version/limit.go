@@ -0,0 +1,40 @@
+package version
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// WithMaxDepth limits the search for the last tag to depth commits. If no matching tag is found
+// within depth commits, a [DepthError] is returned instead of walking the whole history. The depth
+// must be positive.
+func WithMaxDepth(depth int) Option {
+	return func(opts *options) {
+		if depth <= 0 {
+			opts.fail(fmt.Errorf("%w: max depth must be positive", ErrInvalidOption))
+			return
+		}
+		opts.maxDepth = depth
+		opts.record("max-depth", strconv.Itoa(depth))
+	}
+}
+
+// WithExactMatch makes [GitDescribe] fail with [ErrNoMatchingTag] unless the described commit
+// itself is tagged, like git describe --exact-match. The history isn't walked at all.
+func WithExactMatch() Option {
+	return func(opts *options) {
+		opts.exactMatch = true
+		opts.record("exact-match", "true")
+	}
+}
+
+// WithRequireTag makes [GitDescribe] fail with [ErrNoMatchingTag] if no matching tag can be
+// reached from the described commit, instead of counting the commits since the root. If the
+// repository has a commit-graph file, the walk ends as soon as none of the matching tags can be
+// reached anymore, e.g. when the tags of a release line are on another branch.
+func WithRequireTag() Option {
+	return func(opts *options) {
+		opts.requireTag = true
+		opts.record("require-tag", "true")
+	}
+}
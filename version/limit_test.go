@@ -0,0 +1,77 @@
+package version
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitDescribeLimits(t *testing.T) {
+	testBackends(t, func(t *testing.T, backend Option) {
+		dir := t.TempDir()
+		repo, err := git.PlainInit(dir, false)
+		require.NoError(t, err)
+		worktree, err := repo.Worktree()
+		require.NoError(t, err)
+
+		signature := object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Unix(1715601600, 0)}
+		var hashes []plumbing.Hash
+		for range 5 {
+			signature.When = signature.When.Add(time.Minute)
+			hash, err := worktree.Commit("commit", &git.CommitOptions{
+				Author:            &signature,
+				Committer:         &signature,
+				AllowEmptyCommits: true,
+			})
+			require.NoError(t, err)
+			hashes = append(hashes, hash)
+		}
+		_, err = repo.CreateTag("v1.0.0", hashes[0], nil)
+		require.NoError(t, err)
+		signature.When = signature.When.Add(time.Minute)
+		_, err = repo.CreateTag("v1.1.0", hashes[1], &git.CreateTagOptions{Tagger: &signature, Message: "1.1.0"})
+		require.NoError(t, err)
+
+		describe := func(opts ...Option) (*RepoHead, error) {
+			return GitDescribe(dir, append(opts, backend)...)
+		}
+
+		head, err := describe(WithMaxDepth(3))
+		require.NoError(t, err)
+		assert.Equal(t, "v1.1.0", head.LastTag)
+		assert.Equal(t, 3, head.CommitsSinceTag)
+
+		head, err = describe(WithMaxDepth(2))
+		assert.Nil(t, head)
+		require.ErrorIs(t, err, ErrNoMatchingTag)
+		var depthErr *DepthError
+		require.ErrorAs(t, err, &depthErr)
+		assert.Equal(t, 2, depthErr.Depth)
+		require.EqualError(t, err, "no tag within 2 commits")
+
+		head, err = describe(WithMaxDepth(2), WithMatchPattern("v1.0.*"))
+		assert.Nil(t, head)
+		require.ErrorIs(t, err, ErrNoMatchingTag)
+
+		head, err = describe(WithExactMatch())
+		assert.Nil(t, head)
+		require.ErrorIs(t, err, ErrNoMatchingTag)
+		require.EqualError(t, err, "no matching tag: head commit "+hashes[4].String()+" is not tagged")
+
+		_, err = repo.CreateTag("v1.2.0", hashes[4], nil)
+		require.NoError(t, err)
+		head, err = describe(WithExactMatch(), WithMaxDepth(1))
+		require.NoError(t, err)
+		assert.Equal(t, "v1.2.0", head.LastTag)
+		assert.Equal(t, 0, head.CommitsSinceTag)
+
+		_, err = describe(WithMaxDepth(0))
+		require.EqualError(t, err, "invalid option: max depth must be positive")
+		require.ErrorIs(t, err, ErrInvalidOption)
+	})
+}
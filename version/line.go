@@ -0,0 +1,74 @@
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// ReleaseLine identifies a maintenance line of releases sharing the same major and minor version,
+// e.g. 1.4 for 1.4.0, 1.4.1, ...
+type ReleaseLine struct {
+	Major int
+	Minor int
+}
+
+func (l ReleaseLine) String() string {
+	return fmt.Sprintf("%d.%d", l.Major, l.Minor)
+}
+
+var releaseLinePattern = regexp.MustCompile(`(?:^|[^0-9.])(\d+)\.(\d+)(?:\.x)?(?:$|[^0-9.])`)
+
+// ParseReleaseLine parses a release line of the form X.Y. An optional prefix v and suffix .x is
+// accepted, e.g. v1.4.x. An error wrapping [ErrInvalidReleaseLine] is returned otherwise.
+func ParseReleaseLine(input string) (ReleaseLine, error) {
+	major, minor, found := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(input, DefaultPrefix), ".x"), ".")
+	if !found {
+		return ReleaseLine{}, fmt.Errorf("%w %q: must have the form X.Y", ErrInvalidReleaseLine, input)
+	}
+	var (
+		line ReleaseLine
+		err  error
+	)
+	if line.Major, err = strconv.Atoi(major); err != nil {
+		return line, fmt.Errorf("%w %q: failed to parse major version: %w", ErrInvalidReleaseLine, input, err)
+	}
+	if line.Minor, err = strconv.Atoi(minor); err != nil {
+		return line, fmt.Errorf("%w %q: failed to parse minor version: %w", ErrInvalidReleaseLine, input, err)
+	}
+	return line, nil
+}
+
+// ReleaseLineFromBranch derives the release line from the name of a maintenance branch like
+// release/1.4 or support-2.3.x. An error wrapping [ErrInvalidReleaseLine] is returned if the
+// branch doesn't name a release line.
+func ReleaseLineFromBranch(branch string) (ReleaseLine, error) {
+	matches := releaseLinePattern.FindStringSubmatch(branch)
+	if matches == nil {
+		return ReleaseLine{}, fmt.Errorf("%w: branch %q doesn't name a release line", ErrInvalidReleaseLine, branch)
+	}
+	return ParseReleaseLine(matches[1] + "." + matches[2])
+}
+
+// Contains reports whether the version belongs to the release line.
+func (l ReleaseLine) Contains(v Version) bool {
+	return v.Major == l.Major && v.Minor == l.Minor
+}
+
+// CheckTarget returns an error wrapping [ErrInvalidReleaseLine] if bumping to target would leave
+// the release line.
+func (l ReleaseLine) CheckTarget(target Target) error {
+	if target == Minor || target == Major {
+		return fmt.Errorf("%w: target %s would leave release line %s", ErrInvalidReleaseLine, target.String(), l)
+	}
+	return nil
+}
+
+// matches reports whether the tag belongs to the release line. Any prefix in front of the first
+// digit is ignored.
+func (l ReleaseLine) matches(tagName string) bool {
+	return semver.MajorMinor(canonicalTag(tagName)) == DefaultPrefix+l.String()
+}
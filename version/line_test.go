@@ -0,0 +1,68 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReleaseLine(t *testing.T) {
+	for _, s := range []string{"1.4", "v1.4", "1.4.x", "v1.4.x"} {
+		line, err := ParseReleaseLine(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, ReleaseLine{Major: 1, Minor: 4}, line, s)
+		assert.Equal(t, "1.4", line.String())
+	}
+	for _, s := range []string{"1", "1.a", "a.4", "1.4.2"} {
+		_, err := ParseReleaseLine(s)
+		require.ErrorIs(t, err, ErrInvalidReleaseLine, s)
+	}
+}
+
+func TestReleaseLineFromBranch(t *testing.T) {
+	for _, test := range []struct {
+		branch string
+		line   ReleaseLine
+	}{
+		{"release/1.4", ReleaseLine{1, 4}},
+		{"release-2.10.x", ReleaseLine{2, 10}},
+		{"support/v3.0", ReleaseLine{3, 0}},
+		{"1.4", ReleaseLine{1, 4}},
+	} {
+		line, err := ReleaseLineFromBranch(test.branch)
+		require.NoError(t, err, test.branch)
+		assert.Equal(t, test.line, line, test.branch)
+	}
+	for _, branch := range []string{"main", "release/1", "hotfix/1.4.2"} {
+		_, err := ReleaseLineFromBranch(branch)
+		require.ErrorIs(t, err, ErrInvalidReleaseLine, branch)
+	}
+}
+
+func TestReleaseLineCheckTarget(t *testing.T) {
+	line := ReleaseLine{Major: 1, Minor: 4}
+	require.NoError(t, line.CheckTarget(Devel))
+	require.NoError(t, line.CheckTarget(Patch))
+	require.EqualError(t, line.CheckTarget(Minor), "invalid release line: target minor would leave release line 1.4")
+	require.ErrorIs(t, line.CheckTarget(Major), ErrInvalidReleaseLine)
+}
+
+func TestReleaseLineContains(t *testing.T) {
+	line := ReleaseLine{Major: 1, Minor: 4}
+	assert.True(t, line.Contains(Version{Major: 1, Minor: 4, Patch: 2}))
+	assert.False(t, line.Contains(Version{Major: 1, Minor: 5}))
+	assert.False(t, line.Contains(Version{Major: 2, Minor: 4}))
+
+	for tagName, expected := range map[string]bool{
+		"1.4.0":       true,
+		"v1.4.2-rc.1": true,
+		"ver1.4.3":    true,
+		"1.5.0":       false,
+		"1.40.0":      false,
+		"11.4.0":      false,
+		"latest":      false,
+	} {
+		assert.Equal(t, expected, line.matches(tagName), tagName)
+	}
+}
@@ -0,0 +1,150 @@
+package version
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// match reports whether a tag should be considered. Exclude patterns take precedence over
+// include patterns. If no include pattern is given, all tags that are valid X.Y.Z versions or
+// that can be parsed by the parser are included. Finally the tag has to pass all filters, e.g. the
+// one of a release line.
+func (o *options) match(tagName string) bool {
+	for _, exclude := range o.excludes {
+		if exclude(tagName) {
+			return false
+		}
+	}
+	if !o.included(tagName) {
+		return false
+	}
+	for _, filter := range o.filters {
+		if !filter(tagName) {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *options) included(tagName string) bool {
+	if len(o.includes) == 0 {
+		if o.parser != nil {
+			_, err := o.parser.Parse(tagName)
+			return err == nil
+		}
+		return isStrictVersion(tagName)
+	}
+	for _, include := range o.includes {
+		if include(tagName) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithMatchPattern limits the tags that are being considered to the ones matching the given
+// glob pattern. An empty pattern matches all tags. If given multiple times, a tag has to match
+// any of the patterns. An invalid pattern causes [GitDescribe] to fail with [ErrInvalidPattern].
+func WithMatchPattern(pattern string) Option {
+	return func(opts *options) {
+		match, err := globMatcher(pattern)
+		if err != nil {
+			opts.fail(err)
+			return
+		}
+		opts.record("match", pattern)
+		opts.includes = append(opts.includes, match)
+	}
+}
+
+// WithExcludePattern skips the tags matching the given glob pattern, like git describe --exclude.
+// It takes precedence over the include patterns.
+func WithExcludePattern(pattern string) Option {
+	return func(opts *options) {
+		match, err := globMatcher(pattern)
+		if err != nil {
+			opts.fail(err)
+			return
+		}
+		opts.record("exclude", pattern)
+		opts.excludes = append(opts.excludes, match)
+	}
+}
+
+// WithMatchRegexp is like [WithMatchPattern], but expr is a regular expression in the syntax
+// of the regexp package. It isn't anchored, so use ^ and $ to match the whole tag name.
+func WithMatchRegexp(expr string) Option {
+	return func(opts *options) {
+		match, err := regexpMatcher(expr)
+		if err != nil {
+			opts.fail(err)
+			return
+		}
+		opts.record("match-regexp", expr)
+		opts.includes = append(opts.includes, match)
+	}
+}
+
+// WithExcludeRegexp is like [WithExcludePattern], but expr is a regular expression.
+func WithExcludeRegexp(expr string) Option {
+	return func(opts *options) {
+		match, err := regexpMatcher(expr)
+		if err != nil {
+			opts.fail(err)
+			return
+		}
+		opts.record("exclude-regexp", expr)
+		opts.excludes = append(opts.excludes, match)
+	}
+}
+
+// isStrictVersion reports whether the tag is a valid semantic version with all three components
+// and an optional v prefix. golang.org/x/mod/semver also accepts the shorthands vX and vX.Y.
+func isStrictVersion(tagName string) bool {
+	version := ensurePrefix(tagName)
+	return semver.IsValid(version) && strings.HasPrefix(version, semver.Canonical(version))
+}
+
+// compare orders two tag names by their version. Tags that can't be parsed are lower than all
+// others.
+func (o *options) compare(first, second string) int {
+	if o.parser == nil {
+		return semver.Compare(canonicalTag(first), canonicalTag(second))
+	}
+	left, leftErr := o.parser.Parse(first)
+	right, rightErr := o.parser.Parse(second)
+	switch {
+	case leftErr != nil && rightErr != nil:
+		return 0
+	case leftErr != nil:
+		return -1
+	case rightErr != nil:
+		return 1
+	}
+	return left.Compare(right)
+}
+
+func globMatcher(pattern string) (func(string) bool, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidPattern, pattern, err)
+	}
+	return func(tagName string) bool {
+		if pattern == "" {
+			return true
+		}
+		matched, _ := filepath.Match(pattern, tagName)
+		return matched
+	}, nil
+}
+
+func regexpMatcher(expr string) (func(string) bool, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidPattern, expr, err)
+	}
+	return re.MatchString, nil
+}
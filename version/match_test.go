@@ -0,0 +1,108 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	for _, test := range []struct {
+		desc     string
+		opts     []Option
+		included []string
+		excluded []string
+	}{
+		{
+			desc:     "valid versions by default",
+			included: []string{"1.2.3", "v1.2.3", "v2.0.0-rc1"},
+			excluded: []string{"latest", "release-1.2.3", "v2.1", "v3", "1.2.3.4"},
+		},
+		{
+			desc:     "empty pattern matches all",
+			opts:     []Option{WithMatchPattern("")},
+			included: []string{"1.2.3", "latest"},
+		},
+		{
+			desc:     "any include pattern",
+			opts:     []Option{WithMatchPattern("v1.*"), WithMatchPattern("v3.*")},
+			included: []string{"v1.0.0", "v3.1.0"},
+			excluded: []string{"v2.0.0", "1.0.0"},
+		},
+		{
+			desc:     "exclude without include keeps validity check",
+			opts:     []Option{WithExcludePattern("*-nightly*")},
+			included: []string{"v1.0.0", "v1.1.0-rc1"},
+			excluded: []string{"v1.1.0-nightly.3", "legacy-1"},
+		},
+		{
+			desc: "exclude wins over include",
+			opts: []Option{
+				WithMatchPattern("*"),
+				WithExcludePattern("legacy-*"),
+				WithExcludePattern("*-nightly*"),
+			},
+			included: []string{"v1.0.0", "latest"},
+			excluded: []string{"legacy-1.0.0", "v1.1.0-nightly.3"},
+		},
+		{
+			desc:     "lenient parser",
+			opts:     []Option{WithParser(LenientParser(""))},
+			included: []string{"1.2.3", "v2.1", "v3", "v3-rc1"},
+			excluded: []string{"latest", "1.2.3.4"},
+		},
+		{
+			desc:     "include regexp",
+			opts:     []Option{WithMatchRegexp(`^build_\d+_\d+_\d+$`)},
+			included: []string{"build_1_2_3"},
+			excluded: []string{"build_1_2", "v1.2.3"},
+		},
+		{
+			desc:     "exclude regexp wins over include glob",
+			opts:     []Option{WithMatchPattern("v*"), WithExcludeRegexp(`-(alpha|beta)\.?\d*$`)},
+			included: []string{"v1.0.0", "v1.0.0-rc.1"},
+			excluded: []string{"v1.0.0-alpha.1", "v1.0.0-beta"},
+		},
+		{
+			desc:     "filters apply after patterns",
+			opts:     []Option{WithMatchPattern("*"), WithReleaseLine(ReleaseLine{Major: 1, Minor: 4})},
+			included: []string{"v1.4.0", "1.4.2"},
+			excluded: []string{"v1.5.0", "latest"},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			var opts options
+			for _, apply := range test.opts {
+				apply(&opts)
+			}
+			require.NoError(t, opts.err)
+			for _, tagName := range test.included {
+				assert.True(t, opts.match(tagName), tagName)
+			}
+			for _, tagName := range test.excluded {
+				assert.False(t, opts.match(tagName), tagName)
+			}
+		})
+	}
+}
+
+func TestMatchInvalidPattern(t *testing.T) {
+	for _, test := range []struct {
+		opt Option
+		err string
+	}{
+		{WithMatchPattern("v["), `invalid match pattern "v[": syntax error in pattern`},
+		{WithExcludePattern("[a-"), `invalid match pattern "[a-": syntax error in pattern`},
+		{WithMatchRegexp("v("), "invalid match pattern \"v(\": error parsing regexp: missing closing ): `v(`"},
+		{
+			WithExcludeRegexp("*"),
+			"invalid match pattern \"*\": error parsing regexp: missing argument to repetition operator: `*`",
+		},
+	} {
+		var opts options
+		test.opt(&opts)
+		require.ErrorIs(t, opts.err, ErrInvalidPattern)
+		assert.EqualError(t, opts.err, test.err)
+	}
+}
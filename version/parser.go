@@ -0,0 +1,158 @@
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Parser turns the name of a tag into a [Version]. Only the version components, the prefix, the
+// pre-release and the metadata are set by a parser.
+type Parser interface {
+	Parse(tagName string) (Version, error)
+}
+
+type prefixParser struct {
+	prefix  string
+	lenient bool
+}
+
+// PrefixParser returns the default parser for tags of the form <prefix>X.Y.Z-pre+meta. The prefix
+// is optional and defaults to [DefaultPrefix].
+func PrefixParser(prefix string) Parser {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return prefixParser{prefix: prefix}
+}
+
+// LenientParser is like [PrefixParser], but it also accepts tags with one or two components like
+// v2.1 or v3. The missing components are set to zero and [Version.Normalized] is set.
+func LenientParser(prefix string) Parser {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return prefixParser{prefix: prefix, lenient: true}
+}
+
+func (p prefixParser) Parse(tagName string) (Version, error) {
+	var result Version
+	if strings.HasPrefix(tagName, p.prefix) {
+		result.Prefix = p.prefix
+	}
+	version := strings.TrimPrefix(tagName, result.Prefix)
+	if strings.Contains(version, "+") {
+		parts := strings.Split(version, "+")
+		version = parts[0]
+		result.Meta = parts[1]
+	}
+	if strings.Contains(version, "-") {
+		parts := strings.SplitN(version, "-", 2)
+		version = parts[0]
+		result.preRelease = parts[1]
+	}
+
+	parts := strings.Split(version, ".")
+	if p.lenient && len(parts) < 3 {
+		result.Normalized = true
+		for len(parts) < 3 {
+			parts = append(parts, "0")
+		}
+	}
+	if len(parts) != 3 {
+		return result, fmt.Errorf("%w %s: must contain 3 components: X.Y.Z", ErrInvalidTag, tagName)
+	}
+	var err error
+	result.Major, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return result, fmt.Errorf("%w %s: failed to parse major version: %w", ErrInvalidTag, tagName, err)
+	}
+	result.Minor, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return result, fmt.Errorf("%w %s: failed to parse minor version: %w", ErrInvalidTag, tagName, err)
+	}
+	result.Patch, err = strconv.Atoi(parts[2])
+	if err != nil {
+		return result, fmt.Errorf("%w %s: failed to parse patch version: %w", ErrInvalidTag, tagName, err)
+	}
+	return result, nil
+}
+
+// The named groups that are recognized by the [RegexpParser].
+var regexpGroups = []string{"prefix", "major", "minor", "patch", "pre", "meta"}
+
+// RegexpParser parses tags with a regular expression that contains named groups for the
+// components of the version.
+type RegexpParser struct {
+	expr *regexp.Regexp
+}
+
+// NewRegexpParser creates a parser from the regular expression expr. The expression must match
+// the whole tag name and can contain the named groups prefix, major, minor, patch, pre and meta,
+// of which only major is required. Missing minor or patch components are set to zero, which is
+// recorded in [Version.Normalized]. E.g. the
+// expression
+//
+//	release-(?P<major>\d+)\.(?P<minor>\d+)\.(?P<patch>\d+)
+//
+// parses tags like release-2023.4.1. An invalid expression results in an error wrapping
+// [ErrInvalidParser].
+func NewRegexpParser(expr string) (*RegexpParser, error) {
+	compiled, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidParser, expr, err)
+	}
+	names := compiled.SubexpNames()
+	for _, name := range names {
+		if name != "" && !slices.Contains(regexpGroups, name) {
+			return nil, fmt.Errorf("%w %q: unknown named group %s", ErrInvalidParser, expr, name)
+		}
+	}
+	if !slices.Contains(names, "major") {
+		return nil, fmt.Errorf("%w %q: missing named group major", ErrInvalidParser, expr)
+	}
+	return &RegexpParser{expr: compiled}, nil
+}
+
+// Parse implements [Parser]. The pre-release and metadata are sanitized with
+// [SanitizeIdentifier], so that the result is a valid semantic version.
+func (p *RegexpParser) Parse(tagName string) (Version, error) {
+	var result Version
+	matches := p.expr.FindStringSubmatch(tagName)
+	if matches == nil {
+		return result, fmt.Errorf("%w %s: doesn't match %s", ErrInvalidTag, tagName, p.expr)
+	}
+	result.Normalized = p.missing(matches, "minor") || p.missing(matches, "patch")
+	for i, name := range p.expr.SubexpNames() {
+		value := matches[i]
+		if name == "" || value == "" {
+			continue
+		}
+		var err error
+		switch name {
+		case "prefix":
+			result.Prefix = value
+		case "major":
+			result.Major, err = strconv.Atoi(value)
+		case "minor":
+			result.Minor, err = strconv.Atoi(value)
+		case "patch":
+			result.Patch, err = strconv.Atoi(value)
+		case "pre":
+			result.preRelease = SanitizeIdentifier(value)
+		case "meta":
+			result.Meta = SanitizeIdentifier(value)
+		}
+		if err != nil {
+			return result, fmt.Errorf("%w %s: failed to parse %s version: %w", ErrInvalidTag, tagName, name, err)
+		}
+	}
+	return result, nil
+}
+
+func (p *RegexpParser) missing(matches []string, name string) bool {
+	i := p.expr.SubexpIndex(name)
+	return i < 0 || matches[i] == ""
+}
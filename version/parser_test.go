@@ -0,0 +1,121 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefixParser(t *testing.T) {
+	ver, err := PrefixParser("").Parse("v1.2.3-rc1+build.5")
+	require.NoError(t, err)
+	assert.Equal(t, Version{Prefix: "v", Major: 1, Minor: 2, Patch: 3, preRelease: "rc1", Meta: "build.5"}, ver)
+
+	ver, err = PrefixParser("app-").Parse("app-2.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, Version{Prefix: "app-", Major: 2, Minor: 0, Patch: 1}, ver)
+}
+
+func TestLenientParser(t *testing.T) {
+	for _, test := range []struct {
+		tagName string
+		expect  Version
+	}{
+		{"v2.1", Version{Prefix: "v", Major: 2, Minor: 1, Normalized: true}},
+		{"3", Version{Major: 3, Normalized: true}},
+		{"v3-rc1", Version{Prefix: "v", Major: 3, preRelease: "rc1", Normalized: true}},
+		{"1.2.3", Version{Major: 1, Minor: 2, Patch: 3}},
+	} {
+		ver, err := LenientParser("").Parse(test.tagName)
+		require.NoError(t, err)
+		assert.Equal(t, test.expect, ver, test.tagName)
+		assert.Equal(t, test.expect.String(), ver.String())
+	}
+
+	_, err := LenientParser("").Parse("v1.2.3.4")
+	require.ErrorIs(t, err, ErrInvalidTag)
+	_, err = PrefixParser("").Parse("v2.1")
+	require.ErrorIs(t, err, ErrInvalidTag)
+}
+
+func TestRegexpParser(t *testing.T) {
+	for _, test := range []struct {
+		expr    string
+		tagName string
+		expect  Version
+	}{
+		{
+			expr:    `release-(?P<major>\d+)\.(?P<minor>\d+)\.(?P<patch>\d+)`,
+			tagName: "release-2023.4.1",
+			expect:  Version{Major: 2023, Minor: 4, Patch: 1},
+		},
+		{
+			expr:    `(?P<prefix>build_)(?P<major>\d+)_(?P<minor>\d+)_(?P<patch>\d+)`,
+			tagName: "build_1_2_3",
+			expect:  Version{Prefix: "build_", Major: 1, Minor: 2, Patch: 3},
+		},
+		{
+			expr:    `r(?P<major>\d+)(?:\.(?P<minor>\d+))?(?:_(?P<pre>[a-z0-9_]+))?(?:@(?P<meta>.+))?`,
+			tagName: "r7_beta_2@linux/amd64",
+			expect:  Version{Major: 7, preRelease: "beta-2", Meta: "linux-amd64", Normalized: true},
+		},
+		{
+			expr:    `r(?P<major>\d+)(?:\.(?P<minor>\d+))?`,
+			tagName: "r7.3",
+			expect:  Version{Major: 7, Minor: 3, Normalized: true},
+		},
+	} {
+		t.Run(test.tagName, func(t *testing.T) {
+			parser, err := NewRegexpParser(test.expr)
+			require.NoError(t, err)
+			v, err := parser.Parse(test.tagName)
+			require.NoError(t, err)
+			assert.Equal(t, test.expect, v)
+		})
+	}
+}
+
+func TestRegexpParserInvalidTag(t *testing.T) {
+	parser, err := NewRegexpParser(`release-(?P<major>\d+)\.(?P<minor>\d+)`)
+	require.NoError(t, err)
+
+	_, err = parser.Parse("release-1.2.3")
+	require.ErrorIs(t, err, ErrInvalidTag)
+	assert.EqualError(t, err, `invalid version tag release-1.2.3: doesn't match ^(?:release-(?P<major>\d+)\.(?P<minor>\d+))$`)
+
+	_, err = parser.Parse("release-99999999999999999999.1")
+	require.ErrorIs(t, err, ErrInvalidTag)
+	assert.Contains(t, err.Error(), "failed to parse major version")
+}
+
+func TestNewRegexpParserInvalid(t *testing.T) {
+	for _, test := range []struct {
+		expr string
+		err  string
+	}{
+		{`(?P<major>\d+`, "invalid tag parser \"(?P<major>\\\\d+\": error parsing regexp: missing closing ): `^(?:(?P<major>\\d+)$`"},
+		{`(?P<minor>\d+)`, `invalid tag parser "(?P<minor>\\d+)": missing named group major`},
+		{`(?P<major>\d+)\.(?P<build>\d+)`, `invalid tag parser "(?P<major>\\d+)\\.(?P<build>\\d+)": unknown named group build`},
+	} {
+		_, err := NewRegexpParser(test.expr)
+		require.ErrorIs(t, err, ErrInvalidParser)
+		assert.EqualError(t, err, test.err)
+	}
+}
+
+func TestNewFromHeadWithParser(t *testing.T) {
+	parser, err := NewRegexpParser(`build_(?P<major>\d+)_(?P<minor>\d+)_(?P<patch>\d+)`)
+	require.NoError(t, err)
+
+	ver, err := NewFromHeadWithParser(&RepoHead{LastTag: "build_1_2_3", CommitsSinceTag: 2, Hash: "8eaec5d3b0c1f6b8"}, parser)
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.4-dev.2+8eaec5d3", ver.BumpTo(Devel).String())
+
+	ver, err = NewFromHeadWithParser(&RepoHead{CommitsSinceTag: 2, Hash: "8eaec5d3b0c1f6b8"}, parser)
+	require.NoError(t, err)
+	assert.Equal(t, "0.0.1-dev.2+8eaec5d3", ver.BumpTo(Devel).String())
+
+	_, err = NewFromHeadWithParser(&RepoHead{LastTag: "1.2.3"}, parser)
+	require.ErrorIs(t, err, ErrInvalidTag)
+}
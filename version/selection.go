@@ -0,0 +1,110 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/mod/semver"
+)
+
+// Selection is the policy that decides which tag is used if several matching tags point to the
+// same commit. It applies to annotated and lightweight tags alike. The time of a lightweight tag
+// is the commit time.
+type Selection int
+
+const (
+	SelectNewest    Selection = iota // prefers the newest tag, then the highest version
+	SelectHighest                    // prefers the highest version, then the newest tag
+	SelectRelease                    // prefers releases over pre-releases, then the highest version
+	SelectAnnotated                  // prefers annotated over lightweight tags, then the newest tag
+)
+
+// The DefaultSelection policy for tags pointing to the same commit.
+const DefaultSelection = SelectNewest
+
+func (s *Selection) String() string {
+	switch *s {
+	case SelectNewest:
+		return "newest"
+	case SelectHighest:
+		return "highest-semver"
+	case SelectRelease:
+		return "prefer-release"
+	case SelectAnnotated:
+		return "prefer-annotated"
+	default:
+		panic(fmt.Errorf("unexpected selection policy %v", *s))
+	}
+}
+
+func (s *Selection) Set(value string) error {
+	switch value {
+	case "newest":
+		*s = SelectNewest
+	case "highest-semver":
+		*s = SelectHighest
+	case "prefer-release":
+		*s = SelectRelease
+	case "prefer-annotated":
+		*s = SelectAnnotated
+	default:
+		return errors.New(`parse error`)
+	}
+	return nil
+}
+
+// WithSelection sets the policy that decides which tag is used if several tags point to the same
+// commit. It defaults to [DefaultSelection].
+func WithSelection(selection Selection) Option {
+	return func(opts *options) {
+		opts.selection = selection
+		opts.record("select", selection.String())
+	}
+}
+
+// prefer reports whether the candidate should be used instead of the existing tag. The criteria of
+// the selection policy are applied in order until one of them differs.
+func (o *options) prefer(candidate, existing Tag) bool {
+	byTime := func() int { return candidate.When.Compare(existing.When) }
+	byVersion := func() int { return o.compare(candidate.Name, existing.Name) }
+	byRelease := func() int { return compareBool(o.isRelease(candidate.Name), o.isRelease(existing.Name)) }
+	byAnnotated := func() int { return compareBool(candidate.Annotated, existing.Annotated) }
+
+	var criteria []func() int
+	switch o.selection {
+	case SelectHighest:
+		criteria = []func() int{byVersion, byTime}
+	case SelectRelease:
+		criteria = []func() int{byRelease, byVersion, byTime}
+	case SelectAnnotated:
+		criteria = []func() int{byAnnotated, byTime, byVersion}
+	default:
+		criteria = []func() int{byTime, byVersion}
+	}
+	for _, criterion := range criteria {
+		if result := criterion(); result != 0 {
+			return result > 0
+		}
+	}
+	return false
+}
+
+// isRelease reports whether the tag is a version without pre-release.
+func (o *options) isRelease(tagName string) bool {
+	if o.parser == nil {
+		return semver.Prerelease(canonicalTag(tagName)) == ""
+	}
+	ver, err := o.parser.Parse(tagName)
+	return err == nil && ver.PreRelease() == ""
+}
+
+func compareBool(first, second bool) int {
+	switch {
+	case first == second:
+		return 0
+	case first:
+		return 1
+	default:
+		return -1
+	}
+}
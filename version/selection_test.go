@@ -0,0 +1,136 @@
+package version
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectionToString(t *testing.T) {
+	assert.PanicsWithError(t, "unexpected selection policy 8", func() {
+		selection := Selection(8)
+		_ = selection.String()
+	})
+
+	for _, selection := range []Selection{SelectNewest, SelectHighest, SelectRelease, SelectAnnotated} {
+		var parsed Selection
+		require.NoError(t, parsed.Set(selection.String()))
+		assert.Equal(t, selection, parsed)
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	var selection Selection
+	require.EqualError(t, selection.Set("oldest"), "parse error")
+
+	require.NoError(t, selection.Set("highest-semver"))
+	assert.Equal(t, SelectHighest, selection)
+
+	require.NoError(t, selection.Set("prefer-annotated"))
+	assert.Equal(t, SelectAnnotated, selection)
+}
+
+func TestSelectionPrefer(t *testing.T) {
+	now := time.Date(2024, 5, 13, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Hour)
+	for _, test := range []struct {
+		desc      string
+		selection Selection
+		candidate Tag
+		existing  Tag
+		prefer    bool
+	}{
+		{
+			desc:      "newest wins",
+			selection: SelectNewest,
+			candidate: Tag{Name: "1.0.0", When: now},
+			existing:  Tag{Name: "1.1.0", When: earlier},
+			prefer:    true,
+		},
+		{
+			desc:      "newest falls back to highest version",
+			selection: SelectNewest,
+			candidate: Tag{Name: "1.1.0", When: now},
+			existing:  Tag{Name: "1.0.0", When: now},
+			prefer:    true,
+		},
+		{
+			desc:      "newest keeps existing if equal",
+			selection: SelectNewest,
+			candidate: Tag{Name: "v1.0.0", When: now},
+			existing:  Tag{Name: "1.0.0", When: now},
+			prefer:    false,
+		},
+		{
+			desc:      "newest applies to annotated and lightweight tags",
+			selection: SelectNewest,
+			candidate: Tag{Name: "1.0.0", When: now},
+			existing:  Tag{Name: "1.1.0", When: earlier, Annotated: true},
+			prefer:    true,
+		},
+		{
+			desc:      "highest version wins",
+			selection: SelectHighest,
+			candidate: Tag{Name: "1.0.0", When: now},
+			existing:  Tag{Name: "1.1.0", When: earlier},
+			prefer:    false,
+		},
+		{
+			desc:      "highest version compares pre-releases",
+			selection: SelectHighest,
+			candidate: Tag{Name: "1.0.0", When: earlier},
+			existing:  Tag{Name: "1.0.0-rc1", When: now, Annotated: true},
+			prefer:    true,
+		},
+		{
+			desc:      "highest version falls back to newest",
+			selection: SelectHighest,
+			candidate: Tag{Name: "v1.0.0", When: now},
+			existing:  Tag{Name: "1.0.0", When: earlier},
+			prefer:    true,
+		},
+		{
+			desc:      "release wins over higher pre-release",
+			selection: SelectRelease,
+			candidate: Tag{Name: "1.0.0", When: earlier},
+			existing:  Tag{Name: "1.1.0-rc1", When: now},
+			prefer:    true,
+		},
+		{
+			desc:      "release falls back to highest version",
+			selection: SelectRelease,
+			candidate: Tag{Name: "1.1.0-rc2", When: earlier},
+			existing:  Tag{Name: "1.1.0-rc1", When: now},
+			prefer:    true,
+		},
+		{
+			desc:      "annotated wins over newer lightweight",
+			selection: SelectAnnotated,
+			candidate: Tag{Name: "1.0.0", When: now},
+			existing:  Tag{Name: "0.9.0", When: earlier, Annotated: true},
+			prefer:    false,
+		},
+		{
+			desc:      "annotated falls back to newest",
+			selection: SelectAnnotated,
+			candidate: Tag{Name: "0.9.0", When: now, Annotated: true},
+			existing:  Tag{Name: "1.0.0", When: earlier, Annotated: true},
+			prefer:    true,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			opts := options{selection: test.selection}
+			assert.Equal(t, test.prefer, opts.prefer(test.candidate, test.existing))
+		})
+	}
+}
+
+func TestSelectionPreferWithParser(t *testing.T) {
+	parser, err := NewRegexpParser(`r(?P<major>\d+)(?:-(?P<pre>\w+))?`)
+	require.NoError(t, err)
+	opts := options{selection: SelectRelease, parser: parser}
+	assert.True(t, opts.prefer(Tag{Name: "r1"}, Tag{Name: "r2-beta"}))
+	assert.False(t, opts.prefer(Tag{Name: "r2-beta"}, Tag{Name: "r1"}))
+}
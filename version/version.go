@@ -1,11 +1,15 @@
 package version
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"golang.org/x/mod/semver"
 )
 
 // DefaultPrefix that is recognized and ignored by the parser.
@@ -18,6 +22,15 @@ func ensurePrefix(version string) string {
 	return DefaultPrefix + version
 }
 
+// canonicalTag turns a tag name into the form expected by golang.org/x/mod/semver. Any prefix in
+// front of the first digit is replaced by v.
+func canonicalTag(tagName string) string {
+	if i := strings.IndexAny(tagName, "0123456789"); i >= 0 {
+		tagName = tagName[i:]
+	}
+	return DefaultPrefix + tagName
+}
+
 // Predefined format strings to be used with the Format function.
 const (
 	FullFormat    = "x.y.z-p+m"
@@ -94,6 +107,9 @@ type Version struct {
 	preRelease string
 	Commits    int
 	Meta       string
+	Channel    string
+	// Normalized is set if the tag lacked the minor or patch component and it was set to zero.
+	Normalized bool
 }
 
 // BumpTo increases the version to the next patch/minor/major version. The version components with
@@ -147,7 +163,7 @@ func (v Version) Format(format string) (string, error) {
 
 	matches := re.FindStringSubmatch(format)
 	if matches == nil {
-		return "", fmt.Errorf("invalid format: %s", format)
+		return "", fmt.Errorf("%w: %s", ErrInvalidFormat, format)
 	}
 
 	var (
@@ -184,15 +200,61 @@ func (v Version) String() string {
 
 // PreRelease formats the pre-release version depending on the number n of commits since the
 // last tag. If n is zero it returns the parsed pre-release version. If n is greater than zero
-// it will append the string "dev.<n>" to the pre-release version.
+// it will append the string "<channel>.<n>" to the pre-release version, where channel defaults
+// to [DefaultChannel].
 func (v Version) PreRelease() string {
 	if v.Commits == 0 {
 		return v.preRelease
 	}
+	channel := v.Channel
+	if channel == "" {
+		channel = DefaultChannel
+	}
 	if v.preRelease == "" {
-		return fmt.Sprintf("dev.%d", v.Commits)
+		return fmt.Sprintf("%s.%d", channel, v.Commits)
+	}
+	return fmt.Sprintf("%s.%s.%d", v.preRelease, channel, v.Commits)
+}
+
+// Compare returns an integer comparing two versions according to the SemVer precedence rules.
+// The result will be 0 if v == other, -1 if v < other, or +1 if v > other. Prefix and metadata
+// are ignored.
+func (v Version) Compare(other Version) int {
+	return semver.Compare(v.semver(), other.semver())
+}
+
+func (v Version) semver() string {
+	result := fmt.Sprintf("%s%d.%d.%d", DefaultPrefix, v.Major, v.Minor, v.Patch)
+	if pre := v.PreRelease(); pre != "" {
+		result += "-" + pre
 	}
-	return fmt.Sprintf("%s.dev.%d", v.preRelease, v.Commits)
+	return result
+}
+
+// BumpAbove returns a version that is greater than other. If v already is, it is returned
+// unchanged. Otherwise the patch level of other is incremented, while the pre-release suffix for
+// untagged commits and the metadata of v are kept. E.g. 1.2.4-dev.3 bumped above 1.3.0 results
+// in 1.3.1-dev.3.
+func (v Version) BumpAbove(other Version) Version {
+	if v.Compare(other) > 0 {
+		return v
+	}
+	return Version{
+		Prefix:  v.Prefix,
+		Major:   other.Major,
+		Minor:   other.Minor,
+		Patch:   other.Patch + 1,
+		Commits: v.Commits,
+		Meta:    v.Meta,
+		Channel: v.Channel,
+	}
+}
+
+// WithPreRelease returns a copy of the version with the pre-release identifier replaced. The
+// dev.N suffix will still be appended by [Version.PreRelease] if there are commits since the last tag.
+func (v Version) WithPreRelease(preRelease string) Version {
+	v.preRelease = preRelease
+	return v
 }
 
 // NewFromHead creates a new [Version] based on the given head revision, which can be created with
@@ -201,50 +263,116 @@ func (v Version) PreRelease() string {
 // The prefix is an arbitrary string that is prepended to the version number. The not SemVer
 // commpliant but commonly used prefix v will be automatically detected.
 func NewFromHead(head *RepoHead, prefix string) (Version, error) {
-	result := Version{Commits: head.CommitsSinceTag}
-	if prefix == "" {
-		prefix = DefaultPrefix
+	return NewFromHeadWithParser(head, PrefixParser(prefix))
+}
+
+// NewFromHeadWithParser is like [NewFromHead], but the last tag is parsed with the given parser.
+// Use it together with [NewRegexpParser] for tags that don't follow the X.Y.Z scheme. If there is
+// no last tag, the initial version of the head is used as base, which is always parsed leniently.
+func NewFromHeadWithParser(head *RepoHead, parser Parser) (Version, error) {
+	result := Version{}
+	var err error
+	switch {
+	case head.LastTag != "":
+		result, err = parser.Parse(head.LastTag)
+	case head.InitialVersion != "":
+		result, err = LenientParser("").Parse(head.InitialVersion)
+		result.Normalized = false
 	}
-	if strings.HasPrefix(head.LastTag, prefix) {
-		result.Prefix = prefix
+	if err != nil {
+		return result, err
 	}
-	version := strings.TrimPrefix(head.LastTag, result.Prefix)
-	if strings.Contains(version, "+") {
-		parts := strings.Split(version, "+")
-		version = parts[0]
-		result.Meta = parts[1]
-	} else if head.CommitsSinceTag > 0 {
+	result.Commits = head.CommitsSinceTag
+	if result.Meta == "" && head.CommitsSinceTag > 0 {
 		result.Meta = head.Hash[:8]
 	}
-	if strings.Contains(version, "-") {
-		parts := strings.SplitN(version, "-", 2)
-		version = parts[0]
-		result.preRelease = parts[1]
+	return result, nil
+}
+
+// WithPrefix sets the prefix of the version tags, like the prefix argument of [NewFromHead].
+// Unless a parser is given with [WithParser], the tags are parsed with [PrefixParser].
+func WithPrefix(prefix string) Option {
+	return func(opts *options) {
+		opts.prefix = prefix
+		opts.record("prefix", prefix)
+	}
+}
+
+// WithTarget bumps the version calculated by [New] to the given target with [Version.BumpTo].
+// Together with a release line, targets that would leave the line are rejected with
+// [ReleaseLine.CheckTarget].
+func WithTarget(target Target) Option {
+	return func(opts *options) {
+		opts.target = target
+		opts.bump = true
 	}
+}
 
-	if version == "" {
-		result.Major = 0
-		result.Minor = 0
-		result.Patch = 0
-		return result, nil
+// WithMeta replaces the build metadata of the version calculated by [New]. An empty string keeps
+// the default metadata, which is the abbreviated hash of untagged commits.
+func WithMeta(meta string) Option {
+	return func(opts *options) {
+		opts.meta = meta
 	}
+}
 
-	parts := strings.Split(version, ".")
-	if len(parts) != 3 {
-		return result, fmt.Errorf("git version tag must contain 3 components: X.Y.Z: Got %s", version)
+// New calculates a semantic version for the head commit of the repo at path. The options of
+// [GitDescribe] select the tags and the revision, while [WithPrefix], [WithTarget] and [WithMeta]
+// control how the version is derived from the last tag.
+func New(path string, opts ...Option) (Version, error) {
+	return NewContext(context.Background(), path, opts...)
+}
+
+// NewContext is like [New], but the calculation is cancelled once ctx is done, in which case
+// ctx.Err() is returned. See [GitDescribeContext].
+func NewContext(ctx context.Context, path string, opts ...Option) (Version, error) {
+	options, err := newOptions(opts)
+	if err != nil {
+		return Version{}, err
 	}
-	var err error
-	result.Major, err = strconv.Atoi(parts[0])
+	backend, err := options.open(path)
 	if err != nil {
-		return result, fmt.Errorf("failed to parse major version: %w", err)
+		return Version{}, err
 	}
-	result.Minor, err = strconv.Atoi(parts[1])
+	defer closeBackend(backend)
+	return newFromBackend(ctx, backend, options)
+}
+
+// NewFromRepository works like [New] but calculates the version for an already opened go-git
+// repository.
+func NewFromRepository(repo *git.Repository, opts ...Option) (Version, error) {
+	options, err := newOptions(opts)
 	if err != nil {
-		return result, fmt.Errorf("failed to parse minor version: %w", err)
+		return Version{}, err
 	}
-	result.Patch, err = strconv.Atoi(parts[2])
+	backend := newGoGitBackend(repo)
+	defer closeBackend(backend)
+	return newFromBackend(context.Background(), backend, options)
+}
+
+func newFromBackend(ctx context.Context, backend Backend, options *options) (Version, error) {
+	head, err := describe(ctx, backend, options)
 	if err != nil {
-		return result, fmt.Errorf("failed to parse patch version: %w", err)
+		return Version{}, err
+	}
+	parser := options.parser
+	if parser == nil {
+		parser = PrefixParser(options.prefix)
+	}
+	result, err := NewFromHeadWithParser(head, parser)
+	if err != nil {
+		return result, err
+	}
+	if options.bump {
+		if options.line != nil {
+			if err = options.line.CheckTarget(options.target); err != nil {
+				return Version{}, err
+			}
+		}
+		result = result.BumpTo(options.target)
+	}
+	if options.meta != "" {
+		result.Meta = options.meta
 	}
 	return result, nil
 }
@@ -261,11 +389,14 @@ func NewFromHead(head *RepoHead, prefix string) (Version, error) {
 // commpliant but commonly used prefix v will be automatically detected.
 // The glob pattern can be used to limit the tags that are being considered in the calculation. The
 // pattern allows the syntax described for filepath.Match.
+//
+// NewFromRepo is a shorthand for [New] with the options [WithPrefix] and [WithMatchPattern].
 func NewFromRepo(path, prefix, pattern string) (Version, error) {
-	head, err := GitDescribe(path, WithMatchPattern(pattern))
-	if err != nil {
-		return Version{}, err
-	}
-	v, err := NewFromHead(head, prefix)
-	return v, err
+	return NewFromRepoContext(context.Background(), path, prefix, pattern)
+}
+
+// NewFromRepoContext is like [NewFromRepo], but the calculation is cancelled once ctx is done, in
+// which case ctx.Err() is returned.
+func NewFromRepoContext(ctx context.Context, path, prefix, pattern string) (Version, error) {
+	return NewContext(ctx, path, WithPrefix(prefix), WithMatchPattern(pattern))
 }
@@ -1,8 +1,15 @@
 package version
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -21,10 +28,103 @@ func TestNewVersionInvalid(t *testing.T) {
 		"a.2.3",
 	} {
 		_, err := NewFromHead(&RepoHead{LastTag: tagName}, "")
-		require.Error(t, err)
+		require.ErrorIs(t, err, ErrInvalidTag)
 	}
 }
 
+func TestNewFromRepository(t *testing.T) {
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	require.NoError(t, err)
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+
+	signature := object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+	var hash plumbing.Hash
+	for _, tag := range []string{"v1.2.3", "release-2.0.0", ""} {
+		hash, err = worktree.Commit("commit", &git.CommitOptions{
+			Author:            &signature,
+			Committer:         &signature,
+			AllowEmptyCommits: true,
+		})
+		require.NoError(t, err)
+		if tag != "" {
+			_, err = repo.CreateTag(tag, hash, nil)
+			require.NoError(t, err)
+		}
+	}
+
+	ver, err := NewFromRepository(repo, WithPrefix("v"), WithMatchPattern("v*"))
+	require.NoError(t, err)
+	assert.Equal(t, "v1.2.3-dev.2+"+hash.String()[:8], ver.String())
+
+	ver, err = NewFromRepository(repo, WithPrefix("release-"))
+	require.NoError(t, err)
+	assert.Equal(t, "release-2.0.0-dev.1+"+hash.String()[:8], ver.String())
+
+	ver, err = NewFromRepository(repo, WithPrefix("release-"), WithTarget(Minor))
+	require.NoError(t, err)
+	assert.Equal(t, "release-2.1.0", ver.String())
+
+	ver, err = NewFromRepository(repo, WithTarget(Devel), WithMeta("build.7"))
+	require.NoError(t, err)
+	assert.Equal(t, "v1.2.4-dev.2+build.7", ver.String())
+
+	ver, err = NewFromRepository(repo, WithPrefix("release-"), WithRevision("HEAD~1"))
+	require.NoError(t, err)
+	assert.Equal(t, "release-2.0.0", ver.String())
+
+	_, err = NewFromRepository(repo, WithRevision("unknown"))
+	require.ErrorIs(t, err, ErrUnknownRevision)
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+
+	signature := object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+	hash, err := worktree.Commit("commit", &git.CommitOptions{
+		Author:            &signature,
+		Committer:         &signature,
+		AllowEmptyCommits: true,
+	})
+	require.NoError(t, err)
+	_, err = repo.CreateTag("1.2.3", hash, nil)
+	require.NoError(t, err)
+
+	ver, err := New(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3", ver.String())
+
+	ver, err = NewFromRepo(dir, "v", "")
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3", ver.String())
+
+	ver, err = New(dir, WithTarget(Major))
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0", ver.String())
+
+	ver, err = New(dir, WithReleaseLine(ReleaseLine{Major: 1, Minor: 2}), WithTarget(Patch))
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.4", ver.String())
+	_, err = New(dir, WithReleaseLine(ReleaseLine{Major: 1, Minor: 2}), WithTarget(Minor))
+	require.ErrorIs(t, err, ErrInvalidReleaseLine)
+	_, err = New(dir, WithBranchReleaseLine("release/1.2"), WithRevision("master"), WithTarget(Major))
+	require.EqualError(t, err, "invalid release line: target major would leave release line 1.2")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	_, err = NewFromRepoContext(ctx, dir, "", "")
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	_, err = New(dir, WithMatchPattern("["))
+	require.ErrorIs(t, err, ErrInvalidPattern)
+	_, err = New(t.TempDir())
+	require.ErrorIs(t, err, ErrNotRepository)
+}
+
 func TestParse(t *testing.T) {
 	for _, test := range []struct {
 		ref    RepoHead
@@ -118,6 +218,44 @@ func TestString(t *testing.T) {
 
 }
 
+func TestCompare(t *testing.T) {
+	for _, test := range []struct {
+		v      Version
+		w      Version
+		result int
+	}{
+		{Version{Major: 1, Minor: 2, Patch: 4}, Version{Major: 1, Minor: 3}, -1},
+		{Version{Major: 1, Minor: 3}, Version{Prefix: "v", Major: 1, Minor: 3, Meta: "abc"}, 0},
+		{Version{Major: 1, Minor: 3, preRelease: "rc.1"}, Version{Major: 1, Minor: 3}, -1},
+		{Version{Major: 1, Minor: 3, Patch: 1, Commits: 2}, Version{Major: 1, Minor: 3}, 1},
+		{Version{Major: 2}, Version{Major: 1, Minor: 30, Patch: 4}, 1},
+	} {
+		assert.Equal(t, test.result, test.v.Compare(test.w), test.v.String())
+	}
+}
+
+func TestBumpAbove(t *testing.T) {
+	highest := Version{Major: 1, Minor: 3}
+
+	ver := Version{Prefix: "v", Major: 1, Minor: 2, Patch: 4, Commits: 3, Meta: "fcf2c8fa", preRelease: "rc.1"}
+	assert.Equal(t, "v1.3.1-dev.3+fcf2c8fa", ver.BumpAbove(highest).String())
+
+	ver = Version{Major: 1, Minor: 3}
+	assert.Equal(t, "1.3.1", ver.BumpAbove(highest).String())
+
+	ver = Version{Major: 1, Minor: 4, Commits: 2}
+	assert.Equal(t, ver, ver.BumpAbove(highest))
+}
+
+func TestWithPreRelease(t *testing.T) {
+	v := Version{Major: 1, Minor: 2, Patch: 3, Commits: 5}
+	assert.Equal(t, "1.2.3-pr.42.dev.5", v.WithPreRelease("pr.42").String())
+	assert.Equal(t, "1.2.3-dev.5", v.String())
+
+	v.Commits = 0
+	assert.Equal(t, "1.2.3-rc.1", v.WithPreRelease("rc.1").String())
+}
+
 func TestFormat(t *testing.T) {
 	ver := Version{Major: 1, Minor: 2, Patch: 3, Commits: 10, Meta: "fcf2c8f"}
 	for _, test := range []struct {
@@ -171,6 +309,7 @@ func TestFormat(t *testing.T) {
 func TestInvalidFormat(t *testing.T) {
 	v := Version{Major: 1, Minor: 2, Patch: 3}
 	s, err := v.Format("q")
+	require.ErrorIs(t, err, ErrInvalidFormat)
 	require.EqualError(t, err, "invalid format: q")
 	assert.Empty(t, s)
 }
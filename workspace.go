@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/mdomke/git-semver/v6/version"
+)
+
+// workspaceResult is the outcome of a single repository in workspace mode.
+type workspaceResult struct {
+	path        string
+	info        Info
+	err         error
+	diagnostics string
+}
+
+// workspaceEntry is the JSON representation of a workspace result. Failed repositories only
+// contain the error.
+type workspaceEntry struct {
+	*Info
+	Error string `json:"error,omitempty"`
+}
+
+// findRepositories returns the repositories in paths. A path is either a repository itself or a
+// directory that is searched for repositories. Hidden directories and the contents of
+// repositories, e.g. submodules, are not searched. Paths without any repository are returned
+// with an error.
+func findRepositories(paths []string) []workspaceResult {
+	var results []workspaceResult
+	seen := map[string]bool{}
+	add := func(result workspaceResult) {
+		if !seen[result.path] {
+			seen[result.path] = true
+			results = append(results, result)
+		}
+	}
+	for _, root := range paths {
+		root = filepath.Clean(root)
+		found := false
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if path == root {
+					return err
+				}
+				return nil
+			}
+			if !entry.IsDir() {
+				return nil
+			}
+			if path != root && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if _, err = os.Lstat(filepath.Join(path, ".git")); err == nil {
+				found = true
+				add(workspaceResult{path: path})
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if err == nil && !found {
+			err = fmt.Errorf("%w: no repository found in %s", version.ErrNotRepository, root)
+		}
+		if err != nil {
+			add(workspaceResult{path: root, err: err})
+		}
+	}
+	slices.SortFunc(results, func(first, second workspaceResult) int {
+		return strings.Compare(first.path, second.path)
+	})
+	return results
+}
+
+// enclosingRepository returns the absolute path of the repository that contains dir, or an empty
+// string if there is none.
+func enclosingRepository(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err = os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// describeWorkspace computes the versions of the repositories concurrently with at most jobs
+// workers. The warnings of each repository are collected in its diagnostics. Only the repository
+// of the working directory, in which a CI job runs, falls back to the branch and pull request of
+// the CI environment.
+func describeWorkspace(cfg *Config, results []workspaceResult, jobs int) {
+	current := enclosingRepository(".")
+	queue := make(chan *workspaceResult)
+	var workers sync.WaitGroup
+	for range min(jobs, len(results)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for result := range queue {
+				var stderr bytes.Buffer
+				repoCfg := *cfg
+				repoCfg.stderr = &stderr
+				path, err := filepath.Abs(result.path)
+				repoCfg.foreign = err != nil || path != current
+				result.info, result.err = compute(&repoCfg, result.path)
+				result.diagnostics = stderr.String()
+			}
+		}()
+	}
+	for index := range results {
+		if results[index].err == nil {
+			queue <- &results[index]
+		}
+	}
+	close(queue)
+	workers.Wait()
+}
+
+// runWorkspace prints the versions of all repositories found in cfg.args. It returns
+// errWorkspaceFailed if any repository failed, after all results have been printed.
+func runWorkspace(cfg *Config) error {
+	paths := cfg.args
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	jobs := cfg.jobs.value
+	if jobs == 0 {
+		jobs = runtime.NumCPU()
+	}
+	results := findRepositories(paths)
+	describeWorkspace(cfg, results, jobs)
+
+	for _, result := range results {
+		for _, line := range strings.Split(strings.TrimSpace(result.diagnostics), "\n") {
+			if line != "" {
+				fmt.Fprintf(cfg.stderr, "%s: %s\n", result.path, line)
+			}
+		}
+	}
+	var err error
+	if cfg.output == JSONOutput {
+		err = writeWorkspaceJSON(cfg, results)
+	} else {
+		err = writeWorkspaceTable(cfg, results)
+	}
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result.err != nil {
+			return errWorkspaceFailed
+		}
+	}
+	return nil
+}
+
+// errWorkspaceFailed is returned by runWorkspace if the version of a repository couldn't be
+// computed. The individual errors are part of the output.
+var errWorkspaceFailed = errors.New("failed to version some repositories")
+
+// writeWorkspaceTable prints the results as table with aligned columns. The padding of the empty
+// error column is removed.
+func writeWorkspaceTable(cfg *Config, results []workspaceResult) error {
+	var buf bytes.Buffer
+	table := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "PATH\tVERSION\tERROR")
+	for _, result := range results {
+		if result.err != nil {
+			fmt.Fprintf(table, "%s\t-\t%s\n", result.path, result.err)
+		} else {
+			fmt.Fprintf(table, "%s\t%s\t\n", result.path, result.info.Version)
+		}
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if _, err := fmt.Fprintln(cfg.stdout, strings.TrimRight(line, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeWorkspaceJSON(cfg *Config, results []workspaceResult) error {
+	entries := make(map[string]workspaceEntry, len(results))
+	for _, result := range results {
+		if result.err != nil {
+			entries[result.path] = workspaceEntry{Error: result.err.Error()}
+		} else {
+			entries[result.path] = workspaceEntry{Info: &result.info}
+		}
+	}
+	enc := json.NewEncoder(cfg.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+func handleWorkspace(cfg *Config) int {
+	if err := runWorkspace(cfg); err != nil {
+		if !errors.Is(err, errWorkspaceFailed) {
+			fmt.Fprintln(cfg.stderr, err)
+		}
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/mdomke/git-semver/v6/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindRepositories(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{"a", "group/b", ".hidden/c", "a/vendor/d"} {
+		_, err := git.PlainInit(filepath.Join(dir, path), false)
+		require.NoError(t, err)
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "empty"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), nil, 0o600))
+
+	results := findRepositories([]string{dir, filepath.Join(dir, "a"), filepath.Join(dir, "empty"), "/sdf/"})
+	var paths []string
+	for _, result := range results {
+		paths = append(paths, result.path)
+	}
+	assert.Equal(t, []string{
+		"/sdf",
+		filepath.Join(dir, "a"),
+		filepath.Join(dir, "empty"),
+		filepath.Join(dir, "group", "b"),
+	}, paths)
+	require.Error(t, results[0].err)
+	require.NoError(t, results[1].err)
+	require.ErrorIs(t, results[2].err, version.ErrNotRepository)
+	require.NoError(t, results[3].err)
+}
+
+func TestEnclosingRepository(t *testing.T) {
+	dir := t.TempDir()
+	_, err := git.PlainInit(filepath.Join(dir, "repo"), false)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "repo", "sub"), 0o750))
+	assert.Equal(t, filepath.Join(dir, "repo"), enclosingRepository(filepath.Join(dir, "repo", "sub")))
+	assert.Equal(t, filepath.Join(dir, "repo"), enclosingRepository(filepath.Join(dir, "repo")))
+	assert.Empty(t, enclosingRepository(dir))
+}
+
+func TestHandleWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	initTestRepo(t, filepath.Join(dir, "api"), "1.2.3", "")
+	initTestRepo(t, filepath.Join(dir, "web"), "2.0.0")
+	_, err := git.PlainInit(filepath.Join(dir, "empty"), false)
+	require.NoError(t, err)
+
+	setup := func(args ...string) (*Config, *bytes.Buffer, *bytes.Buffer) {
+		var stdout, stderr bytes.Buffer
+		cfg := Config{
+			workspace: true,
+			format:    version.NoMetaFormat,
+			args:      args,
+			stdout:    &stdout,
+			stderr:    &stderr,
+		}
+		return &cfg, &stdout, &stderr
+	}
+
+	t.Run("Table output", func(t *testing.T) {
+		cfg, stdout, stderr := setup(dir)
+		assert.Equal(t, exitError, handleWorkspace(cfg))
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		require.Len(t, lines, 4)
+		assert.Equal(t, []string{"PATH", "VERSION", "ERROR"}, strings.Fields(lines[0]))
+		assert.Equal(t, []string{filepath.Join(dir, "api"), "1.2.4-dev.1"}, strings.Fields(lines[1]))
+		assert.False(t, strings.HasSuffix(lines[1], " "))
+		assert.True(t, strings.HasPrefix(lines[2], filepath.Join(dir, "empty")+" "))
+		assert.Contains(t, lines[2], "failed to retrieve repo head")
+		assert.Equal(t, []string{filepath.Join(dir, "web"), "2.0.0"}, strings.Fields(lines[3]))
+		assert.Empty(t, stderr.String())
+	})
+
+	t.Run("JSON output", func(t *testing.T) {
+		cfg, stdout, _ := setup(filepath.Join(dir, "api"), filepath.Join(dir, "web"), filepath.Join(dir, "missing"))
+		cfg.output = JSONOutput
+		cfg.jobs = limit{value: 1, set: true}
+		assert.Equal(t, exitError, handleWorkspace(cfg))
+		var entries map[string]struct {
+			Version string `json:"version"`
+			LastTag string `json:"lastTag"`
+			Error   string `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &entries))
+		require.Len(t, entries, 3)
+		assert.Equal(t, "1.2.4-dev.1", entries[filepath.Join(dir, "api")].Version)
+		assert.Equal(t, "1.2.3", entries[filepath.Join(dir, "api")].LastTag)
+		assert.Empty(t, entries[filepath.Join(dir, "api")].Error)
+		assert.Equal(t, "2.0.0", entries[filepath.Join(dir, "web")].Version)
+		assert.Empty(t, entries[filepath.Join(dir, "missing")].Version)
+		assert.NotEmpty(t, entries[filepath.Join(dir, "missing")].Error)
+	})
+
+	t.Run("All repositories succeed", func(t *testing.T) {
+		cfg, stdout, _ := setup(filepath.Join(dir, "api"), filepath.Join(dir, "web"))
+		cfg.prefix = "v"
+		assert.Equal(t, exitOK, handleWorkspace(cfg))
+		assert.Contains(t, stdout.String(), "v1.2.4-dev.1")
+		assert.Contains(t, stdout.String(), "v2.0.0")
+	})
+
+	t.Run("CI branch only applies to the current repository", func(t *testing.T) {
+		repo, err := git.PlainOpen(filepath.Join(dir, "web"))
+		require.NoError(t, err)
+		head, err := repo.Head()
+		require.NoError(t, err)
+		require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, head.Hash())))
+		getenv := func(key string) string {
+			return map[string]string{
+				"GITHUB_ACTIONS":  "true",
+				"GITHUB_REF":      "refs/pull/7/merge",
+				"GITHUB_HEAD_REF": "feature/foo",
+			}[key]
+		}
+		describe := func() workspaceEntry {
+			t.Helper()
+			cfg, stdout, _ := setup(filepath.Join(dir, "web"))
+			cfg.output = JSONOutput
+			cfg.getenv = getenv
+			assert.Equal(t, exitOK, handleWorkspace(cfg))
+			var entries map[string]workspaceEntry
+			require.NoError(t, json.Unmarshal(stdout.Bytes(), &entries))
+			return entries[filepath.Join(dir, "web")]
+		}
+
+		entry := describe()
+		assert.Empty(t, entry.Branch)
+		assert.Empty(t, entry.PullRequest)
+		assert.Equal(t, "github", entry.CI)
+
+		wd, err := os.Getwd()
+		require.NoError(t, err)
+		require.NoError(t, os.Chdir(filepath.Join(dir, "web")))
+		defer os.Chdir(wd) // nolint: errcheck
+		entry = describe()
+		assert.Equal(t, "feature/foo", entry.Branch)
+		assert.Equal(t, "7", entry.PullRequest)
+	})
+	t.Run("Diagnostics are prefixed with the path", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "api", ".git", "git-semver-cache.json"), []byte("{"), 0o600))
+		cfg, _, stderr := setup(filepath.Join(dir, "api"))
+		cfg.verbose = true
+		cfg.cache = true
+		assert.Equal(t, exitOK, handleWorkspace(cfg))
+		assert.Equal(t, filepath.Join(dir, "api")+": Ignoring the cache: unexpected end of JSON input\n", stderr.String())
+	})
+}